@@ -0,0 +1,57 @@
+package api
+
+import (
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Endpoints builds the URLs of the API endpoints on a base URL, so the whole tool can be
+// pointed at a stub server or a mirror. Path segments and query values are escaped.
+type Endpoints struct {
+	base string
+}
+
+// New returns the endpoints of the API served at baseURL, like the api.base_url config value
+func New(baseURL string) *Endpoints {
+	return &Endpoints{base: strings.TrimRight(baseURL, "/")}
+}
+
+// Showings lists the showings of a film at a cinema
+func (e *Endpoints) Showings(cinemaID, filmID string) string {
+	return e.url("api/microservice/showings/cinemas/"+url.PathEscape(cinemaID)+"/films", "filmId="+url.QueryEscape(filmID))
+}
+
+// ShowingsForDate lists the showings of every film at a cinema on the showing date of date
+func (e *Endpoints) ShowingsForDate(cinemaID string, date time.Time) string {
+	return e.url("api/microservice/showings/cinemas/"+url.PathEscape(cinemaID)+"/films", "showingDate="+date.Format("2006-01-02")+"T00:00:00")
+}
+
+// Seats returns the seat map and occupancy of a session
+func (e *Endpoints) Seats(cinemaID, sessionID string) string {
+	return e.url("api/microservice/booking/Session/"+url.PathEscape(cinemaID)+"/"+url.PathEscape(sessionID)+"/seats", "")
+}
+
+// Cinemas lists the cinemas by region
+func (e *Endpoints) Cinemas() string {
+	return e.url("api/microservice/showings/cinemas", "")
+}
+
+// Films lists the films currently showing
+func (e *Endpoints) Films() string {
+	return e.url("api/microservice/showings/films", "")
+}
+
+// RefreshToken exchanges the refresh token cookie for a new access token. The path is inferred from the
+// api/microservice/<service> layout of the other endpoints and the microservicesRefreshToken cookie,
+// not taken from a request of the site: auth.token_refresh stays off until it is checked against it.
+func (e *Endpoints) RefreshToken() string {
+	return e.url("api/microservice/auth/refresh", "")
+}
+
+func (e *Endpoints) url(path, query string) string {
+	if query == "" {
+		return e.base + "/" + path
+	}
+	return e.base + "/" + path + "?" + query
+}
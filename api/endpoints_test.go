@@ -0,0 +1,67 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEndpoints(t *testing.T) {
+	date := time.Date(2025, 9, 15, 21, 30, 0, 0, time.Local)
+	tests := []struct {
+		name     string
+		baseURL  string
+		build    func(e *Endpoints) string
+		expected string
+	}{
+		{
+			name:     "showings",
+			baseURL:  "https://www.thespacecinema.it/",
+			build:    func(e *Endpoints) string { return e.Showings("1018", "HO00001234") },
+			expected: "https://www.thespacecinema.it/api/microservice/showings/cinemas/1018/films?filmId=HO00001234",
+		},
+		{
+			name:     "showings for date",
+			baseURL:  "https://www.thespacecinema.it",
+			build:    func(e *Endpoints) string { return e.ShowingsForDate("1018", date) },
+			expected: "https://www.thespacecinema.it/api/microservice/showings/cinemas/1018/films?showingDate=2025-09-15T00:00:00",
+		},
+		{
+			name:     "seats",
+			baseURL:  "http://localhost:8080/",
+			build:    func(e *Endpoints) string { return e.Seats("1018", "12345") },
+			expected: "http://localhost:8080/api/microservice/booking/Session/1018/12345/seats",
+		},
+		{
+			name:     "ids are escaped",
+			baseURL:  "http://localhost:8080",
+			build:    func(e *Endpoints) string { return e.Seats("10/18", "a b") },
+			expected: "http://localhost:8080/api/microservice/booking/Session/10%2F18/a%20b/seats",
+		},
+		{
+			name:     "mirror under a path",
+			baseURL:  "https://mirror.example.com/thespace/",
+			build:    func(e *Endpoints) string { return e.Cinemas() },
+			expected: "https://mirror.example.com/thespace/api/microservice/showings/cinemas",
+		},
+		{
+			name:     "films",
+			baseURL:  "https://www.thespacecinema.it/",
+			build:    func(e *Endpoints) string { return e.Films() },
+			expected: "https://www.thespacecinema.it/api/microservice/showings/films",
+		},
+		{
+			name:     "refresh token",
+			baseURL:  "https://www.thespacecinema.it/",
+			build:    func(e *Endpoints) string { return e.RefreshToken() },
+			expected: "https://www.thespacecinema.it/api/microservice/auth/refresh",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.build(New(tc.baseURL)))
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package backfill
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/paologalligit/go-extractor/entities"
+	"github.com/paologalligit/go-extractor/persistence"
+)
+
+// maxLineSize bounds a single NDJSON line of the seat counts log
+const maxLineSize = 1024 * 1024
+
+// File names written by the fetch and track commands; the site is missing from the older ones
+var (
+	showingsFilePattern = regexp.MustCompile(`^showings_(?:(.+)_)?(\d{8}_\d{6})\.json$`)
+	todayFilePattern    = regexp.MustCompile(`^todaySession-(?:(.+)-)?(\d{4}-\d{2}-\d{2})\.json$`)
+)
+
+type ImportOptions struct {
+	Importer persistence.Importer
+	// Paths are seat counts logs (.log, .ndjson), showings files (.json) or directories holding them
+	Paths []string
+}
+
+// FileSummary reports the outcome of importing one file
+type FileSummary struct {
+	Path      string
+	Inserted  int
+	Skipped   int
+	Malformed int
+	// Err is set when the file could not be read or parsed as a whole
+	Err error
+}
+
+// Summary reports the outcome of an import
+type Summary struct {
+	Files     []FileSummary
+	Inserted  int
+	Skipped   int
+	Malformed int
+}
+
+func (s *Summary) add(file FileSummary) {
+	s.Files = append(s.Files, file)
+	s.Inserted += file.Inserted
+	s.Skipped += file.Skipped
+	s.Malformed += file.Malformed
+}
+
+// RunImport loads every file under options.Paths into the database. Rows already stored are
+// skipped, so importing the same files twice is harmless. Malformed rows and unreadable files
+// are reported in the summary and do not stop the import; database errors do.
+func RunImport(ctx context.Context, options *ImportOptions) (*Summary, error) {
+	files, err := collectFiles(options.Paths)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &Summary{}
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return summary, err
+		}
+		var fileSummary FileSummary
+		var err error
+		if isSeatLog(file) {
+			fileSummary, err = importSeatLog(ctx, options.Importer, file)
+		} else {
+			fileSummary, err = importShowings(ctx, options.Importer, file)
+		}
+		summary.add(fileSummary)
+		if err != nil {
+			return summary, err
+		}
+	}
+	return summary, nil
+}
+
+// collectFiles expands directories into the importable files they contain
+func collectFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot import %s: %w", path, err)
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			name := d.Name()
+			if isSeatLog(name) || showingsFilePattern.MatchString(name) || todayFilePattern.MatchString(name) {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("cannot walk %s: %w", path, err)
+		}
+	}
+	return files, nil
+}
+
+func isSeatLog(path string) bool {
+	ext := filepath.Ext(path)
+	return ext == ".log" || ext == ".ndjson"
+}
+
+// importSeatLog loads an NDJSON file of entities.SeatLogEntry written by FilePersistence
+func importSeatLog(ctx context.Context, importer persistence.Importer, path string) (FileSummary, error) {
+	summary := FileSummary{Path: path}
+	file, err := os.Open(path)
+	if err != nil {
+		summary.Err = err
+		return summary, nil
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var entry entities.SeatLogEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil || entry.SessionId == "" || entry.LoggedAt.IsZero() {
+			summary.Malformed++
+			continue
+		}
+		inserted, err := importer.ImportSessionSeats(ctx, entry)
+		if err != nil {
+			return summary, err
+		}
+		if inserted {
+			summary.Inserted++
+		} else {
+			summary.Skipped++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		summary.Err = err
+	}
+	return summary, nil
+}
+
+// importShowings loads a JSON array of entities.ShowingResult, as written by the fetch and track commands
+func importShowings(ctx context.Context, importer persistence.Importer, path string) (FileSummary, error) {
+	summary := FileSummary{Path: path}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		summary.Err = err
+		return summary, nil
+	}
+	var results []entities.ShowingResult
+	if err := json.Unmarshal(data, &results); err != nil {
+		summary.Err = fmt.Errorf("not a showings file: %w", err)
+		return summary, nil
+	}
+	fetchedAt, fileSite, err := fetchedAt(path)
+	if err != nil {
+		summary.Err = err
+		return summary, nil
+	}
+
+	for _, result := range results {
+		for _, group := range result.ShowingGroups {
+			for _, session := range group.Sessions {
+				startAt, err := time.ParseInLocation("2006-01-02T15:04:05", session.StartTime, time.UTC)
+				if err != nil || session.SessionId == "" || result.CinemaId == "" {
+					summary.Malformed++
+					continue
+				}
+				site := result.Site
+				if site == "" {
+					site = fileSite
+				}
+				inserted, err := importer.ImportSessionSnapshot(ctx, entities.SessionSnapshot{
+					Site:       site,
+					CinemaId:   result.CinemaId,
+					CinemaName: result.CinemaName,
+					FilmId:     result.FilmId,
+					FilmName:   result.Movie,
+					Session:    session,
+					StartAt:    startAt,
+					FetchedAt:  fetchedAt,
+				})
+				if err != nil {
+					return summary, err
+				}
+				if inserted {
+					summary.Inserted++
+				} else {
+					summary.Skipped++
+				}
+			}
+		}
+	}
+	return summary, nil
+}
+
+// fetchedAt returns when a showings file was fetched, and the site named by the file if any:
+// from its name when it follows the fetch or track naming, else from its modification time
+func fetchedAt(path string) (time.Time, string, error) {
+	name := filepath.Base(path)
+	if m := showingsFilePattern.FindStringSubmatch(name); m != nil {
+		t, err := time.ParseInLocation("20060102_150405", m[2], time.Local)
+		return t, m[1], err
+	}
+	if m := todayFilePattern.FindStringSubmatch(name); m != nil {
+		t, err := time.ParseInLocation("2006-01-02", m[2], time.Local)
+		return t, m[1], err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+	return info.ModTime().Truncate(time.Second), "", nil
+}
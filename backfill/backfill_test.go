@@ -0,0 +1,126 @@
+package backfill
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/paologalligit/go-extractor/entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryImporter stores rows in maps keyed like the Postgres uniqueness checks
+type memoryImporter struct {
+	seats     map[string]entities.SeatLogEntry
+	snapshots map[string]entities.SessionSnapshot
+}
+
+func newMemoryImporter() *memoryImporter {
+	return &memoryImporter{
+		seats:     make(map[string]entities.SeatLogEntry),
+		snapshots: make(map[string]entities.SessionSnapshot),
+	}
+}
+
+func (m *memoryImporter) ImportSessionSeats(ctx context.Context, entry entities.SeatLogEntry) (bool, error) {
+	key := entry.SessionId + "@" + entry.LoggedAt.String()
+	if _, ok := m.seats[key]; ok {
+		return false, nil
+	}
+	m.seats[key] = entry
+	return true, nil
+}
+
+func (m *memoryImporter) ImportSessionSnapshot(ctx context.Context, snapshot entities.SessionSnapshot) (bool, error) {
+	key := fmt.Sprintf("%s/%s@%s", snapshot.CinemaId, snapshot.Session.SessionId, snapshot.FetchedAt)
+	if _, ok := m.snapshots[key]; ok {
+		return false, nil
+	}
+	m.snapshots[key] = snapshot
+	return true, nil
+}
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestRunImport(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "seat_counts.log", `{"cinemaName":"Torino","filmName":"A","sessionId":"1","seats":10,"loggedAt":"2025-09-18T20:00:00Z","startHour":"19:50"}
+{"cinemaName":"Torino","filmName":"A","sessionId":"1","seats":10,"loggedAt":"2025-09-18T20:00:00Z","startHour":"19:50"}
+not json
+{"cinemaName":"Torino","filmName":"A","seats":3,"loggedAt":"2025-09-18T20:00:00Z"}
+
+{"cinemaName":"Torino","filmName":"B","sessionId":"2","seats":4,"loggedAt":"2025-09-18T21:00:00Z","startHour":"20:50"}
+`)
+	writeFile(t, dir, "showings_20250915_130905.json", `[{"movie":"A","filmId":"F1","cinemaId":"1030","cinemaName":"Vimercate","showingGroups":[{"date":"2025-09-15T00:00:00","sessions":[
+		{"sessionId":"97499","startTime":"2025-09-15T15:15:00","seats":12,"totalSeats":100},
+		{"sessionId":"97500","startTime":"later"}
+	]}]}]`)
+	writeFile(t, dir, "todaySession-2025-09-15.json", `{"not":"an array"}`)
+	writeFile(t, dir, "notes.txt", "ignored")
+
+	importer := newMemoryImporter()
+	summary, err := RunImport(context.Background(), &ImportOptions{Importer: importer, Paths: []string{dir}})
+	require.NoError(t, err)
+
+	assert.Len(t, summary.Files, 3)
+	assert.Equal(t, 3, summary.Inserted)
+	assert.Equal(t, 1, summary.Skipped)
+	assert.Equal(t, 3, summary.Malformed)
+	for _, file := range summary.Files {
+		if filepath.Base(file.Path) == "todaySession-2025-09-15.json" {
+			assert.Error(t, file.Err)
+		} else {
+			assert.NoError(t, file.Err)
+		}
+	}
+	fetched := time.Date(2025, 9, 15, 13, 9, 5, 0, time.Local)
+	snapshot := importer.snapshots[fmt.Sprintf("1030/97499@%s", fetched)]
+	if assert.NotEmpty(t, snapshot.CinemaId, "snapshot keyed by the fetch time in the file name") {
+		assert.Equal(t, 12, snapshot.Session.Seats)
+	}
+
+	// Importing again only finds duplicates
+	summary, err = RunImport(context.Background(), &ImportOptions{Importer: importer, Paths: []string{dir}})
+	require.NoError(t, err)
+	assert.Equal(t, 0, summary.Inserted)
+	assert.Equal(t, 4, summary.Skipped)
+}
+
+func TestRunImport_MissingPath(t *testing.T) {
+	_, err := RunImport(context.Background(), &ImportOptions{
+		Importer: newMemoryImporter(),
+		Paths:    []string{filepath.Join(t.TempDir(), "missing.log")},
+	})
+	assert.Error(t, err)
+}
+
+func TestFetchedAt(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected time.Time
+		site     string
+	}{
+		{name: "showings_20250915_130905.json", expected: time.Date(2025, 9, 15, 13, 9, 5, 0, time.Local)},
+		{name: "showings_vue-uk_20250915_130905.json", expected: time.Date(2025, 9, 15, 13, 9, 5, 0, time.Local), site: "vue-uk"},
+		{name: "todaySession-2025-09-15.json", expected: time.Date(2025, 9, 15, 0, 0, 0, 0, time.Local)},
+		{name: "todaySession-vue-uk-2025-09-15.json", expected: time.Date(2025, 9, 15, 0, 0, 0, 0, time.Local), site: "vue-uk"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fetched, site, err := fetchedAt(tc.name)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, fetched)
+			assert.Equal(t, tc.site, site)
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Interaction is one recorded request/response pair, stored as NNNNNN.json in the cassette directory
+type Interaction struct {
+	Seq    int         `json:"seq"`
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   string      `json:"body"`
+	// RecordedAt is when the response was received, on the clock of the recorded run
+	RecordedAt time.Time `json:"recordedAt"`
+}
+
+// Recorder writes every response of the transports it wraps into its directory, in one sequence.
+// Request cookies are never written to the cassette, and Set-Cookie headers are dropped.
+type Recorder struct {
+	dir   string
+	clock func() time.Time
+
+	mu  sync.Mutex
+	seq int
+}
+
+// NewRecorder records into dir, after the interactions it already holds
+func NewRecorder(dir string) (*Recorder, error) {
+	return NewRecorderWithClock(dir, time.Now)
+}
+
+// NewRecorderWithClock records into dir, stamping each interaction with the time given by clock
+func NewRecorderWithClock(dir string, clock func() time.Time) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cassette %s: %w", dir, err)
+	}
+	interactions, err := Load(dir)
+	if err != nil {
+		return nil, err
+	}
+	r := &Recorder{dir: dir, clock: clock}
+	if len(interactions) > 0 {
+		r.seq = interactions[len(interactions)-1].Seq
+	}
+	return r, nil
+}
+
+// Wrap returns a transport recording the responses of next into r, for client.Options.WrapTransport.
+// The clients of several sites can share one recorder.
+func (r *Recorder) Wrap(next http.RoundTripper) http.RoundTripper {
+	return &recording{recorder: r, next: next}
+}
+
+// recording is the transport returned by Recorder.Wrap
+type recording struct {
+	recorder *Recorder
+	next     http.RoundTripper
+}
+
+func (t *recording) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		// Network errors are not replayable: the replay answers with the next recorded response
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	t.recorder.record(req, resp, body)
+	return resp, nil
+}
+
+func (r *Recorder) record(req *http.Request, resp *http.Response, body []byte) {
+	header := resp.Header.Clone()
+	header.Del("Set-Cookie")
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seq++
+	interaction := Interaction{
+		Seq:        r.seq,
+		Method:     req.Method,
+		URL:        req.URL.String(),
+		Status:     resp.StatusCode,
+		Header:     header,
+		Body:       string(body),
+		RecordedAt: r.clock(),
+	}
+	if err := write(filepath.Join(r.dir, fmt.Sprintf("%06d.json", r.seq)), interaction); err != nil {
+		fmt.Printf("❌ Failed to record %s: %v\n", interaction.URL, err)
+	}
+}
+
+func write(path string, interaction Interaction) error {
+	data, err := json.MarshalIndent(interaction, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// Load reads the interactions of the cassette in dir, in recording order
+func Load(dir string) ([]Interaction, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var interactions []Interaction
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		var interaction Interaction
+		if err := json.Unmarshal(data, &interaction); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+		interactions = append(interactions, interaction)
+	}
+	sort.Slice(interactions, func(i, j int) bool {
+		return interactions[i].Seq < interactions[j].Seq
+	})
+	return interactions, nil
+}
+
+// Replayer is an http.RoundTripper answering from a cassette, without network access.
+// The responses recorded for the same request are served in recording order,
+// the last one again once they are used up.
+type Replayer struct {
+	recordedAt time.Time
+
+	mu        sync.Mutex
+	responses map[string][]Interaction
+}
+
+// NewReplayer loads the cassette in dir
+func NewReplayer(dir string) (*Replayer, error) {
+	interactions, err := Load(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(interactions) == 0 {
+		return nil, fmt.Errorf("cassette %s has no recorded interaction", dir)
+	}
+	r := &Replayer{recordedAt: interactions[0].RecordedAt, responses: map[string][]Interaction{}}
+	for _, interaction := range interactions {
+		k := key(interaction.Method, interaction.URL)
+		r.responses[k] = append(r.responses[k], interaction)
+	}
+	return r, nil
+}
+
+// RecordedAt returns when the recording started, zero for a cassette recorded without timestamps
+func (r *Replayer) RecordedAt() time.Time {
+	return r.recordedAt
+}
+
+// Wrap replaces next with r, for client.Options.WrapTransport: no request leaves the process
+func (r *Replayer) Wrap(next http.RoundTripper) http.RoundTripper {
+	return r
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+	k := key(req.Method, req.URL.String())
+	r.mu.Lock()
+	queue := r.responses[k]
+	if len(queue) == 0 {
+		r.mu.Unlock()
+		return nil, fmt.Errorf("cassette has no recorded response for %s", k)
+	}
+	interaction := queue[0]
+	if len(queue) > 1 {
+		r.responses[k] = queue[1:]
+	}
+	r.mu.Unlock()
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", interaction.Status, http.StatusText(interaction.Status)),
+		StatusCode:    interaction.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        interaction.Header.Clone(),
+		Body:          io.NopCloser(strings.NewReader(interaction.Body)),
+		ContentLength: int64(len(interaction.Body)),
+		Request:       req,
+	}, nil
+}
+
+func key(method, url string) string {
+	return method + " " + url
+}
@@ -0,0 +1,92 @@
+package cassette
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/paologalligit/go-extractor/client"
+	"github.com/paologalligit/go-extractor/header"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	occupancy := []string{`{"result": {"sessionOccupancy": 0.25}}`, `{"result": {"sessionOccupancy": 0.5}}`}
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/seats":
+			http.SetCookie(w, &http.Cookie{Name: "token", Value: "secret"})
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(occupancy[min(requests, len(occupancy)-1)]))
+			requests++
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	seatsURL := server.URL + "/seats"
+	goneURL := server.URL + "/gone"
+
+	// Record, on the clock of the recorded run
+	recordedAt := time.Date(2025, 9, 15, 20, 0, 0, 0, time.UTC)
+	recorder, err := NewRecorderWithClock(dir, func() time.Time { return recordedAt })
+	require.NoError(t, err)
+	recording := client.NewWithOptions(header.NoAuthProvider{}, client.Options{WrapTransport: recorder.Wrap})
+	for _, expected := range []float64{0.25, 0.5} {
+		resp, err := recording.CallSeats(context.Background(), seatsURL)
+		require.NoError(t, err)
+		assert.Equal(t, expected, resp.Result.SessionOccupancy)
+	}
+	_, err = recording.CallSeats(context.Background(), goneURL)
+	assert.ErrorIs(t, err, client.ErrNotFound)
+	server.Close()
+
+	interactions, err := Load(dir)
+	require.NoError(t, err)
+	require.Len(t, interactions, 3)
+	assert.Equal(t, seatsURL, interactions[0].URL)
+	assert.Equal(t, "application/json", interactions[0].Header.Get("Content-Type"))
+	assert.Empty(t, interactions[0].Header.Values("Set-Cookie"), "cookies must not be written to the cassette")
+	assert.Equal(t, http.StatusNotFound, interactions[2].Status)
+	assert.True(t, recordedAt.Equal(interactions[0].RecordedAt))
+
+	// Replay, with the server gone
+	replayer, err := NewReplayer(dir)
+	require.NoError(t, err)
+	assert.True(t, recordedAt.Equal(replayer.RecordedAt()))
+	replaying := client.NewWithOptions(header.NoAuthProvider{}, client.Options{WrapTransport: replayer.Wrap})
+	for _, expected := range []float64{0.25, 0.5, 0.5} {
+		resp, err := replaying.CallSeats(context.Background(), seatsURL)
+		require.NoError(t, err)
+		assert.Equal(t, expected, resp.Result.SessionOccupancy)
+	}
+	_, err = replaying.CallSeats(context.Background(), goneURL)
+	assert.ErrorIs(t, err, client.ErrNotFound)
+	_, err = replaying.CallSeats(context.Background(), server.URL+"/never-recorded")
+	assert.ErrorContains(t, err, "no recorded response")
+
+	// Recording again appends to the cassette
+	recorder, err = NewRecorder(dir)
+	require.NoError(t, err)
+	assert.Equal(t, 3, recorder.seq)
+}
+
+func TestNewReplayer_EmptyCassette(t *testing.T) {
+	_, err := NewReplayer(t.TempDir())
+	assert.ErrorContains(t, err, "no recorded interaction")
+}
+
+func TestClock(t *testing.T) {
+	origin := time.Date(2025, 9, 15, 20, 0, 0, 0, time.UTC)
+	clock := NewClock(origin, 3600)
+	assert.WithinDuration(t, origin, clock.Now(), time.Minute)
+
+	// An hour of the recorded day passes in a second
+	fired := <-clock.After(30 * time.Minute)
+	assert.False(t, fired.Before(origin.Add(30*time.Minute)))
+	assert.WithinDuration(t, origin.Add(30*time.Minute), clock.Now(), 30*time.Minute)
+}
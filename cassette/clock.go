@@ -0,0 +1,31 @@
+package cassette
+
+import "time"
+
+// Clock runs from the time a cassette was recorded, speed times faster than the wall clock,
+// so that a replay fires the timers of the recorded day in order without waiting for them
+type Clock struct {
+	origin  time.Time
+	started time.Time
+	speed   float64
+}
+
+// NewClock returns a clock reading origin now and moving speed simulated seconds per real second
+func NewClock(origin time.Time, speed float64) *Clock {
+	return &Clock{origin: origin, started: time.Now(), speed: speed}
+}
+
+// Now returns the simulated time
+func (c *Clock) Now() time.Time {
+	elapsed := time.Since(c.started)
+	return c.origin.Add(time.Duration(float64(elapsed) * c.speed))
+}
+
+// After waits for d on the simulated clock, then sends the simulated time
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	time.AfterFunc(time.Duration(float64(d)/c.speed), func() {
+		ch <- c.Now()
+	})
+	return ch
+}
@@ -0,0 +1,185 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/paologalligit/go-extractor/api"
+	"github.com/paologalligit/go-extractor/client"
+	"github.com/paologalligit/go-extractor/entities"
+)
+
+// Catalogs kept by the cache, stored as <name>.json
+const (
+	Cinemas = "cinemas"
+	Films   = "films"
+)
+
+// Names lists the catalogs in the order they are refreshed
+var Names = []string{Cinemas, Films}
+
+// Status tells what Ensure or Refresh did with a catalog
+type Status string
+
+const (
+	// StatusFresh: the stored copy is younger than the TTL, the API was not called
+	StatusFresh Status = "fresh"
+	// StatusNotModified: the API confirmed that the stored copy is current
+	StatusNotModified Status = "not modified"
+	// StatusFetched: a new copy was downloaded and stored
+	StatusFetched Status = "fetched"
+	// StatusStale: the API call failed, the stored copy is used past its TTL
+	StatusStale Status = "stale"
+)
+
+// Cache keeps the cinemas and films catalogs of a site in Dir, and asks the API for them again
+// once they are older than TTL, conditionally when the API sent an ETag or Last-Modified
+type Cache struct {
+	Dir       string
+	TTL       time.Duration
+	Client    *client.ExtractorClient
+	Endpoints *api.Endpoints
+	// now is replaced in tests
+	now func() time.Time
+}
+
+func New(dir string, ttl time.Duration, c *client.ExtractorClient, endpoints *api.Endpoints) *Cache {
+	return &Cache{Dir: dir, TTL: ttl, Client: c, Endpoints: endpoints, now: time.Now}
+}
+
+// meta is stored next to a catalog as <name>.meta.json
+type meta struct {
+	FetchedAt  time.Time         `json:"fetchedAt"`
+	Validators client.Validators `json:"validators"`
+}
+
+// Ensure makes sure a usable copy of the catalog name is stored, fetching it when it is missing
+// or older than the TTL. When the API fails, an expired copy is still used.
+func (c *Cache) Ensure(ctx context.Context, name string) (Status, error) {
+	stored, ok := c.readMeta(name)
+	if ok && c.now().Sub(stored.FetchedAt) < c.TTL {
+		return StatusFresh, nil
+	}
+	status, err := c.Refresh(ctx, name)
+	if err != nil && ok && ctx.Err() == nil {
+		fmt.Printf("⚠️  Using the %s fetched at %s: %v\n", name, stored.FetchedAt.Format(time.RFC3339), err)
+		return StatusStale, nil
+	}
+	return status, err
+}
+
+// Refresh asks the API for the catalog name whatever the age of the stored copy
+func (c *Cache) Refresh(ctx context.Context, name string) (Status, error) {
+	url, err := c.url(name)
+	if err != nil {
+		return "", err
+	}
+	stored, ok := c.readMeta(name)
+	if !ok {
+		stored = meta{}
+	}
+	resp, err := c.Client.GetIfModified(ctx, url, stored.Validators)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch %s: %w", name, err)
+	}
+	if resp.NotModified {
+		stored.FetchedAt = c.now()
+		return StatusNotModified, c.writeMeta(name, stored)
+	}
+	if err := c.validate(name, url, resp.Body); err != nil {
+		return "", fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	if err := writeAtomic(c.path(name), resp.Body); err != nil {
+		return "", fmt.Errorf("failed to store %s: %w", name, err)
+	}
+	return StatusFetched, c.writeMeta(name, meta{FetchedAt: c.now(), Validators: resp.Validators})
+}
+
+func (c *Cache) url(name string) (string, error) {
+	switch name {
+	case Cinemas:
+		return c.Endpoints.Cinemas(), nil
+	case Films:
+		return c.Endpoints.Films(), nil
+	default:
+		return "", fmt.Errorf("unknown catalog %q", name)
+	}
+}
+
+// validate checks that body decodes as the catalog name, so a broken answer never replaces a good copy
+func (c *Cache) validate(name, url string, body []byte) error {
+	switch name {
+	case Cinemas:
+		return c.Client.Decode(name, url, body, &entities.CinemasFile{})
+	default:
+		return c.Client.Decode(name, url, body, &entities.FilmsFile{})
+	}
+}
+
+func (c *Cache) path(name string) string {
+	return filepath.Join(c.Dir, name+".json")
+}
+
+func (c *Cache) metaPath(name string) string {
+	return filepath.Join(c.Dir, name+".meta.json")
+}
+
+// readMeta returns the metadata of the stored copy of name, if there is one.
+// A copy stored before the cache existed has no metadata: its modification time is used.
+func (c *Cache) readMeta(name string) (meta, bool) {
+	info, err := os.Stat(c.path(name))
+	if err != nil {
+		return meta{}, false
+	}
+	var m meta
+	data, err := os.ReadFile(c.metaPath(name))
+	if err == nil {
+		err = json.Unmarshal(data, &m)
+	}
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			fmt.Printf("⚠️  Ignoring %s: %v\n", c.metaPath(name), err)
+		}
+		return meta{FetchedAt: info.ModTime()}, true
+	}
+	return m, true
+}
+
+func (c *Cache) writeMeta(name string, m meta) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeAtomic(c.metaPath(name), data); err != nil {
+		return fmt.Errorf("failed to store the metadata of %s: %w", name, err)
+	}
+	return nil
+}
+
+// writeAtomic replaces path with data, so readers never see a partial file
+func writeAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,158 @@
+package catalog
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/paologalligit/go-extractor/api"
+	"github.com/paologalligit/go-extractor/client"
+	"github.com/paologalligit/go-extractor/header"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// catalogServer serves body on the cinemas endpoint with an ETag, or fails with status
+type catalogServer struct {
+	mu       sync.Mutex
+	body     string
+	etag     string
+	status   int
+	requests int
+	ifMatch  string
+}
+
+func (s *catalogServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	s.ifMatch = r.Header.Get("If-None-Match")
+	if s.status != 0 {
+		w.WriteHeader(s.status)
+		return
+	}
+	w.Header().Set("ETag", s.etag)
+	if s.ifMatch == s.etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Write([]byte(s.body))
+}
+
+const (
+	cinemasV1 = `{"result":[{"cinemas":[{"cinemaId":"1030","cinemaName":"Vimercate"}]}]}`
+	cinemasV2 = `{"result":[{"cinemas":[{"cinemaId":"1018","cinemaName":"Torino"}]}]}`
+)
+
+func newTestCache(t *testing.T, server *catalogServer, now *time.Time) *Cache {
+	t.Helper()
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+	c := New(t.TempDir(), time.Hour, client.NewWithOptions(header.NoAuthProvider{}, client.Options{}), api.New(httpServer.URL))
+	c.now = func() time.Time { return *now }
+	return c
+}
+
+func TestCache_Ensure(t *testing.T) {
+	now := time.Date(2025, 9, 15, 10, 0, 0, 0, time.UTC)
+	server := &catalogServer{body: cinemasV1, etag: `"v1"`}
+	cache := newTestCache(t, server, &now)
+	ctx := context.Background()
+	stored := func() string {
+		data, err := os.ReadFile(filepath.Join(cache.Dir, "cinemas.json"))
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	tests := []struct {
+		name     string
+		after    time.Duration
+		setup    func()
+		status   Status
+		requests int
+		ifMatch  string
+		body     string
+	}{
+		{name: "missing", status: StatusFetched, requests: 1, body: cinemasV1},
+		{name: "younger than the TTL", after: 59 * time.Minute, status: StatusFresh, requests: 1, body: cinemasV1},
+		{name: "unchanged", after: time.Hour, status: StatusNotModified, requests: 2, ifMatch: `"v1"`, body: cinemasV1},
+		{name: "fresh again", after: 30 * time.Minute, status: StatusFresh, requests: 2, body: cinemasV1},
+		{
+			name:  "changed",
+			after: 2 * time.Hour,
+			setup: func() {
+				server.body, server.etag = cinemasV2, `"v2"`
+			},
+			status: StatusFetched, requests: 3, ifMatch: `"v1"`, body: cinemasV2,
+		},
+		{
+			name:  "API failing",
+			after: 2 * time.Hour,
+			setup: func() {
+				server.status = http.StatusInternalServerError
+			},
+			status: StatusStale, requests: 4, ifMatch: `"v2"`, body: cinemasV2,
+		},
+		{
+			name:  "broken answer",
+			after: 2 * time.Hour,
+			setup: func() {
+				server.status, server.body, server.etag = 0, `{"result": [`, `"v3"`
+			},
+			status: StatusStale, requests: 5, ifMatch: `"v2"`, body: cinemasV2,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			now = now.Add(tc.after)
+			server.ifMatch = ""
+			if tc.setup != nil {
+				tc.setup()
+			}
+			status, err := cache.Ensure(ctx, Cinemas)
+			require.NoError(t, err)
+			assert.Equal(t, tc.status, status)
+			assert.Equal(t, tc.requests, server.requests)
+			assert.Equal(t, tc.ifMatch, server.ifMatch)
+			assert.Equal(t, tc.body, stored())
+		})
+	}
+}
+
+func TestCache_Refresh(t *testing.T) {
+	now := time.Date(2025, 9, 15, 10, 0, 0, 0, time.UTC)
+	server := &catalogServer{body: cinemasV1, etag: `"v1"`}
+	cache := newTestCache(t, server, &now)
+	ctx := context.Background()
+
+	// A copy stored before the cache existed is dated by its modification time
+	path := filepath.Join(cache.Dir, "cinemas.json")
+	require.NoError(t, os.WriteFile(path, []byte(cinemasV2), 0644))
+	require.NoError(t, os.Chtimes(path, now, now))
+	status, err := cache.Ensure(ctx, Cinemas)
+	require.NoError(t, err)
+	assert.Equal(t, StatusFresh, status)
+	assert.Equal(t, 0, server.requests)
+
+	// Refresh ignores the TTL
+	status, err = cache.Refresh(ctx, Cinemas)
+	require.NoError(t, err)
+	assert.Equal(t, StatusFetched, status)
+	status, err = cache.Refresh(ctx, Cinemas)
+	require.NoError(t, err)
+	assert.Equal(t, StatusNotModified, status)
+	assert.Equal(t, 2, server.requests)
+
+	// Without a stored copy, failures are errors
+	server.status = http.StatusInternalServerError
+	_, err = cache.Ensure(ctx, Films)
+	assert.ErrorIs(t, err, client.ErrServer)
+	_, err = cache.Refresh(ctx, "showings")
+	assert.ErrorContains(t, err, `unknown catalog "showings"`)
+}
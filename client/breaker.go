@@ -0,0 +1,207 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Endpoints guarded by the circuit breaker
+const (
+	EndpointShowings = "showings"
+	EndpointSeats    = "seats"
+)
+
+// halfOpenWait is the retry time given to the calls rejected while a probe is in flight
+const halfOpenWait = time.Second
+
+// ErrCircuitOpen: the call was not sent, its circuit is open after consecutive failures
+var ErrCircuitOpen = errors.New("circuit open")
+
+// CircuitOpenError is returned instead of calling an endpoint whose circuit is open
+type CircuitOpenError struct {
+	Circuit string
+	// RetryAt is when the circuit lets a call through again
+	RetryAt time.Time
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%s: %s until %s", e.Circuit, ErrCircuitOpen, e.RetryAt.Format(time.RFC3339))
+}
+
+func (e *CircuitOpenError) Unwrap() error {
+	return ErrCircuitOpen
+}
+
+// BreakerSettings configures the circuit breaker of a client. A zero Threshold disables it.
+type BreakerSettings struct {
+	// Threshold is the number of consecutive failed calls opening a circuit
+	Threshold int
+	// Cooldown is how long an open circuit rejects the calls before letting one probe through
+	Cooldown time.Duration
+	// PerCinema gives each cinema its own circuit on every endpoint
+	PerCinema bool
+}
+
+// CircuitState is the state of a circuit
+type CircuitState string
+
+const (
+	// CircuitClosed: calls go through
+	CircuitClosed CircuitState = "closed"
+	// CircuitOpen: calls are rejected until the cool-down ends
+	CircuitOpen CircuitState = "open"
+	// CircuitHalfOpen: one probe call goes through, its outcome closes or opens the circuit again
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+// breaker keeps one circuit per endpoint, or per endpoint and cinema, of a host.
+// A nil breaker lets every call through.
+type breaker struct {
+	settings BreakerSettings
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+	// now is replaced in tests
+	now func() time.Time
+}
+
+type circuit struct {
+	state    CircuitState
+	failures int
+	// openUntil is the end of the cool-down of an open circuit
+	openUntil time.Time
+	probing   bool
+}
+
+func newBreaker(settings BreakerSettings) *breaker {
+	if settings.Threshold <= 0 {
+		return nil
+	}
+	return &breaker{settings: settings, circuits: map[string]*circuit{}, now: time.Now}
+}
+
+// circuitName names the circuit guarding a call to rawURL on endpoint
+func (b *breaker) circuitName(endpoint, rawURL string) string {
+	name := endpoint
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return name
+	}
+	name += "@" + u.Host
+	if b.settings.PerCinema {
+		if m := cinemaPattern.FindStringSubmatch(u.Path); m != nil {
+			name += "/" + m[1]
+		}
+	}
+	return name
+}
+
+// allow returns the function recording the outcome of the call to rawURL on endpoint,
+// or a *CircuitOpenError when the call must not be sent
+func (b *breaker) allow(endpoint, rawURL string) (func(ctx context.Context, err error), error) {
+	if b == nil {
+		return func(context.Context, error) {}, nil
+	}
+	name := b.circuitName(endpoint, rawURL)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c, ok := b.circuits[name]
+	if !ok {
+		c = &circuit{state: CircuitClosed}
+		b.circuits[name] = c
+	}
+	now := b.now()
+	if c.state == CircuitOpen {
+		if now.Before(c.openUntil) {
+			return nil, &CircuitOpenError{Circuit: name, RetryAt: c.openUntil}
+		}
+		c.state = CircuitHalfOpen
+		fmt.Printf("🔌 Circuit %s half-open, letting a probe through\n", name)
+	}
+	if c.state == CircuitHalfOpen {
+		if c.probing {
+			return nil, &CircuitOpenError{Circuit: name, RetryAt: now.Add(halfOpenWait)}
+		}
+		c.probing = true
+	}
+	return func(ctx context.Context, err error) {
+		b.record(name, c, ctx, err)
+	}, nil
+}
+
+// record updates the circuit with the outcome of a call. A call cancelled by its caller, as on shutdown,
+// tells nothing about the endpoint, while a call cut by its deadline is a failure: the endpoint did not
+// answer in time. An answer that is not a retryable failure or a malformed body counts as a success.
+func (b *breaker) record(name string, c *circuit, ctx context.Context, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	probe := c.state == CircuitHalfOpen && c.probing
+	if probe {
+		c.probing = false
+	}
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return
+	}
+	timedOut := err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded)
+	if !timedOut && !Retryable(err) && !errors.Is(err, ErrDecode) {
+		if c.state != CircuitClosed {
+			fmt.Printf("✅ Circuit %s closed\n", name)
+		}
+		c.state, c.failures = CircuitClosed, 0
+		return
+	}
+	c.failures++
+	if probe || (c.state == CircuitClosed && c.failures >= b.settings.Threshold) {
+		c.state = CircuitOpen
+		c.openUntil = b.now().Add(b.settings.Cooldown)
+		fmt.Printf("🔌 Circuit %s open for %s after %d consecutive failures: %v\n", name, b.settings.Cooldown, c.failures, err)
+	}
+}
+
+// CircuitStatus is a snapshot of a circuit
+type CircuitStatus struct {
+	Name     string
+	State    CircuitState
+	Failures int
+	// RetryAt is the end of the cool-down of an open circuit
+	RetryAt time.Time
+}
+
+// states returns the circuits that are not closed, by name
+func (b *breaker) states() []CircuitStatus {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var statuses []CircuitStatus
+	for name, c := range b.circuits {
+		if c.state == CircuitClosed {
+			continue
+		}
+		status := CircuitStatus{Name: name, State: c.state, Failures: c.failures}
+		if c.state == CircuitOpen {
+			status.RetryAt = c.openUntil
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// PrintCircuits writes one line per circuit that is not closed
+func PrintCircuits(w io.Writer, circuits []CircuitStatus) {
+	for _, c := range circuits {
+		if c.State == CircuitOpen {
+			fmt.Fprintf(w, "🔌 Circuit %s open after %d consecutive failures, retrying at %s\n", c.Name, c.Failures, c.RetryAt.Format(time.RFC3339))
+		} else {
+			fmt.Fprintf(w, "🔌 Circuit %s %s after %d consecutive failures\n", c.Name, c.State, c.Failures)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/paologalligit/go-extractor/header"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractorClient_CircuitBreaker(t *testing.T) {
+	var mu sync.Mutex
+	status := http.StatusInternalServerError
+	requests := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests[r.URL.Path]++
+		w.WriteHeader(status)
+		w.Write([]byte(`{"result": {}}`))
+	}))
+	defer server.Close()
+	setStatus := func(s int) {
+		mu.Lock()
+		defer mu.Unlock()
+		status = s
+	}
+
+	now := time.Date(2025, 9, 15, 20, 0, 0, 0, time.UTC)
+	c := NewWithOptions(header.NoAuthProvider{}, Options{Breaker: BreakerSettings{Threshold: 2, Cooldown: time.Minute, PerCinema: true}})
+	c.breaker.now = func() time.Time { return now }
+	ctx := context.Background()
+	seats := server.URL + "/api/microservice/booking/Session/1030/1/seats"
+	other := server.URL + "/api/microservice/booking/Session/1018/1/seats"
+
+	// Two consecutive failures open the circuit of the seats of 1030
+	for range 2 {
+		_, err := c.CallSeats(ctx, seats)
+		assert.ErrorIs(t, err, ErrServer)
+	}
+	_, err := c.CallSeats(ctx, seats)
+	var open *CircuitOpenError
+	require.ErrorAs(t, err, &open)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.False(t, Retryable(err))
+	assert.Equal(t, now.Add(time.Minute), open.RetryAt)
+	assert.Equal(t, 2, requests["/api/microservice/booking/Session/1030/1/seats"])
+	assert.Equal(t, []CircuitStatus{{Name: "seats@" + server.Listener.Addr().String() + "/1030", State: CircuitOpen, Failures: 2, RetryAt: now.Add(time.Minute)}}, c.Circuits())
+	var out bytes.Buffer
+	PrintCircuits(&out, c.Circuits())
+	assert.Equal(t, "🔌 Circuit seats@"+server.Listener.Addr().String()+"/1030 open after 2 consecutive failures, retrying at 2025-09-15T20:01:00Z\n", out.String())
+
+	// The other cinemas and endpoints have their own circuits
+	_, err = c.CallSeats(ctx, other)
+	assert.ErrorIs(t, err, ErrServer)
+	_, err = c.CallShowings(ctx, server.URL+"/api/microservice/showings/cinemas/1030/films?filmId=F1")
+	assert.ErrorIs(t, err, ErrServer)
+
+	// After the cool-down a failed probe opens the circuit again
+	now = now.Add(time.Minute)
+	_, err = c.CallSeats(ctx, seats)
+	assert.ErrorIs(t, err, ErrServer)
+	_, err = c.CallSeats(ctx, seats)
+	require.ErrorAs(t, err, &open)
+	assert.Equal(t, now.Add(time.Minute), open.RetryAt)
+
+	// A successful probe closes it
+	now = now.Add(time.Minute)
+	setStatus(http.StatusOK)
+	_, err = c.CallSeats(ctx, seats)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, requests["/api/microservice/booking/Session/1030/1/seats"])
+	assert.Len(t, c.Circuits(), 0)
+}
+
+func TestBreaker_Allow(t *testing.T) {
+	now := time.Date(2025, 9, 15, 20, 0, 0, 0, time.UTC)
+	b := newBreaker(BreakerSettings{Threshold: 1, Cooldown: time.Minute})
+	b.now = func() time.Time { return now }
+	url := "https://www.thespacecinema.it/api/microservice/booking/Session/1030/1/seats"
+	ctx := context.Background()
+	serverErr := &HTTPError{Kind: ErrServer, StatusCode: 500}
+
+	// Not found and unauthorized are answers: they keep the circuit closed
+	for _, err := range []error{nil, &HTTPError{Kind: ErrNotFound, StatusCode: 404}, &HTTPError{Kind: ErrUnauthorized, StatusCode: 401}} {
+		done, allowErr := b.allow(EndpointSeats, url)
+		require.NoError(t, allowErr)
+		done(ctx, err)
+	}
+	// A call abandoned by its caller does not count
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	done, err := b.allow(EndpointSeats, url)
+	require.NoError(t, err)
+	done(cancelled, context.Canceled)
+	assert.Empty(t, b.states())
+
+	done, err = b.allow(EndpointSeats, url)
+	require.NoError(t, err)
+	done(ctx, serverErr)
+	_, err = b.allow(EndpointSeats, url)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+
+	// Half-open: one probe at a time, the others retry shortly
+	now = now.Add(time.Minute)
+	probe, err := b.allow(EndpointSeats, url)
+	require.NoError(t, err)
+	_, err = b.allow(EndpointSeats, url)
+	var open *CircuitOpenError
+	require.ErrorAs(t, err, &open)
+	assert.Equal(t, now.Add(halfOpenWait), open.RetryAt)
+	assert.Equal(t, CircuitHalfOpen, b.states()[0].State)
+	probe(ctx, nil)
+	_, err = b.allow(EndpointSeats, url)
+	assert.NoError(t, err)
+
+	// A zero threshold disables the breaker
+	assert.Nil(t, newBreaker(BreakerSettings{}))
+}
@@ -0,0 +1,106 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxErrorBody bounds the response body kept in an HTTPError
+const maxErrorBody = 512
+
+// Sentinels matched with errors.Is on the errors returned by ExtractorClient
+var (
+	// ErrUnauthorized: still 401 or 403 after the cookies were refreshed
+	ErrUnauthorized = errors.New("authentication rejected")
+	// ErrNotFound: 404, e.g. a session cancelled or sold out of the seat map
+	ErrNotFound = errors.New("not found")
+	// ErrRateLimited: 429
+	ErrRateLimited = errors.New("rate limited")
+	// ErrServer: 5xx, the API is down or failing
+	ErrServer = errors.New("server error")
+	// ErrUnexpectedStatus: any other non-2xx status
+	ErrUnexpectedStatus = errors.New("unexpected status")
+	// ErrDecode: a 2xx response whose body is not the expected JSON
+	ErrDecode = errors.New("malformed response")
+)
+
+// HTTPError describes a failed API call. Kind is one of the sentinels above,
+// Cause the underlying error when there is one (e.g. the JSON error for ErrDecode).
+type HTTPError struct {
+	Kind       error
+	URL        string
+	StatusCode int
+	// Body is the start of the response body, truncated to maxErrorBody bytes
+	Body string
+	// RetryAfter is the wait asked by a 429 or 503 response, zero when absent
+	RetryAfter time.Duration
+	Cause      error
+}
+
+func (e *HTTPError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "GET %s: %s (status %d)", e.URL, e.Kind, e.StatusCode)
+	if e.Cause != nil {
+		fmt.Fprintf(&b, ": %v", e.Cause)
+	}
+	if e.Body != "" {
+		fmt.Fprintf(&b, ", body: %q", e.Body)
+	}
+	return b.String()
+}
+
+func (e *HTTPError) Unwrap() []error {
+	if e.Cause == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Cause}
+}
+
+// newHTTPError classifies a response status, nil for 2xx
+func newHTTPError(url string, status int, header http.Header, body []byte) *HTTPError {
+	var kind error
+	switch {
+	case status >= 200 && status <= 299:
+		return nil
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		kind = ErrUnauthorized
+	case status == http.StatusNotFound:
+		kind = ErrNotFound
+	case status == http.StatusTooManyRequests:
+		kind = ErrRateLimited
+	case status >= 500:
+		kind = ErrServer
+	default:
+		kind = ErrUnexpectedStatus
+	}
+	httpErr := &HTTPError{Kind: kind, URL: url, StatusCode: status, Body: truncate(body)}
+	if status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable {
+		httpErr.RetryAfter = parseRetryAfter(header.Get("Retry-After"), time.Now())
+	}
+	return httpErr
+}
+
+// parseRetryAfter reads a Retry-After header, given in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(at.Sub(now), 0)
+	}
+	return 0
+}
+
+func truncate(body []byte) string {
+	if len(body) <= maxErrorBody {
+		return string(body)
+	}
+	return string(body[:maxErrorBody]) + "…"
+}
@@ -1,74 +1,329 @@
 package client
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"time"
 
 	"github.com/paologalligit/go-extractor/entities"
 	"github.com/paologalligit/go-extractor/header"
+	"github.com/paologalligit/go-extractor/schema"
 )
 
+// Extractor calls the showings and seats endpoints. Cancelling ctx aborts the call,
+// including the request in flight and the waits for the rate limiter and the retries.
 type Extractor interface {
-	CallShowings(url string) (*entities.ShowingResponse, error)
-	CallSeats(url string) (*entities.Response, error)
+	CallShowings(ctx context.Context, url string) (*entities.ShowingResponse, error)
+	CallSeats(ctx context.Context, url string) (*entities.Response, error)
 }
 
 type ExtractorClient struct {
-	client        *http.Client
-	cookieManager *header.CookiesManager
+	client      *http.Client
+	credentials header.CredentialProvider
+	retry       RetryPolicy
+	limiter     *RateLimiter
+	timeouts    Timeouts
+	locale      string
+	breaker     *breaker
+	schema      *schema.Report
+	// sleep waits between attempts, replaced in tests
+	sleep func(ctx context.Context, d time.Duration) error
 }
 
-func New(cookieManager *header.CookiesManager) *ExtractorClient {
+// Options configures an ExtractorClient
+type Options struct {
+	Retry RetryPolicy
+	// Limiter paces every request, retries included; share one across the clients of a process
+	Limiter  *RateLimiter
+	Timeouts Timeouts
+	// Locale is sent as Accept-Language, like it-IT, when not empty
+	Locale string
+	// WrapTransport decorates the transport carrying the requests, like the cassette recorder
+	WrapTransport func(http.RoundTripper) http.RoundTripper
+	// Breaker stops calling the showings and seats endpoints after consecutive failures
+	Breaker BreakerSettings
+	// Schema, when set, checks each decoded response against the entities and aggregates the drifts;
+	// a response missing a required field fails with ErrDecode instead of decoding to zeros
+	Schema *schema.Report
+}
+
+// Timeouts bound each attempt of a call; zero disables the matching timeout
+type Timeouts struct {
+	// Connect bounds the TCP connection and the TLS handshake
+	Connect time.Duration
+	// Read bounds the wait for the response headers once the request is sent
+	Read time.Duration
+	// Overall bounds an attempt from the connection to the end of the body
+	Overall time.Duration
+}
+
+// DefaultTimeouts matches the requests.timeout defaults of the config
+func DefaultTimeouts() Timeouts {
+	return Timeouts{
+		Connect: 10 * time.Second,
+		Read:    30 * time.Second,
+		Overall: time.Minute,
+	}
+}
+
+// New returns a client retrying with DefaultRetryPolicy and bounded by DefaultTimeouts
+func New(credentials header.CredentialProvider) *ExtractorClient {
+	return NewWithOptions(credentials, Options{Retry: DefaultRetryPolicy(), Timeouts: DefaultTimeouts()})
+}
+
+func NewWithOptions(credentials header.CredentialProvider, opts Options) *ExtractorClient {
+	client := header.NewHTTPClient(credentials)
+	client.Transport = newTransport(opts.Timeouts)
+	if opts.WrapTransport != nil {
+		client.Transport = opts.WrapTransport(client.Transport)
+	}
 	return &ExtractorClient{
-		client:        &http.Client{},
-		cookieManager: cookieManager,
+		client:      client,
+		credentials: credentials,
+		retry:       opts.Retry,
+		limiter:     opts.Limiter,
+		timeouts:    opts.Timeouts,
+		locale:      opts.Locale,
+		breaker:     newBreaker(opts.Breaker),
+		schema:      opts.Schema,
+		sleep:       sleepContext,
 	}
 }
 
+// newTransport is the default transport with the connect and read timeouts
+func newTransport(timeouts Timeouts) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	dialer := &net.Dialer{Timeout: timeouts.Connect, KeepAlive: 30 * time.Second}
+	transport.DialContext = dialer.DialContext
+	transport.TLSHandshakeTimeout = timeouts.Connect
+	transport.ResponseHeaderTimeout = timeouts.Read
+	return transport
+}
+
 // CallShowings fetches showings and unmarshals into ShowingResponse
-func (c *ExtractorClient) CallShowings(url string) (*entities.ShowingResponse, error) {
-	body, err := c.doGet(url)
-	if err != nil {
-		return nil, err
-	}
+func (c *ExtractorClient) CallShowings(ctx context.Context, url string) (*entities.ShowingResponse, error) {
 	var resp entities.ShowingResponse
-	if err := json.Unmarshal(body, &resp); err != nil {
+	if err := c.guardedJSON(ctx, EndpointShowings, url, &resp); err != nil {
 		return nil, err
 	}
 	return &resp, nil
 }
 
 // CallSeats fetches seat data and unmarshals into Response
-func (c *ExtractorClient) CallSeats(url string) (*entities.Response, error) {
-	body, err := c.doGet(url)
+func (c *ExtractorClient) CallSeats(ctx context.Context, url string) (*entities.Response, error) {
+	var resp entities.Response
+	if err := c.guardedJSON(ctx, EndpointSeats, url, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Schema returns the report aggregating the schema drifts of the responses, nil when not checking them
+func (c *ExtractorClient) Schema() *schema.Report {
+	return c.schema
+}
+
+// Circuits returns the circuits of the breaker that are not closed
+func (c *ExtractorClient) Circuits() []CircuitStatus {
+	return c.breaker.states()
+}
+
+// Get returns the body of a successful response to url, with the retries, rate limit and
+// re-authentication of the other calls; for the endpoints stored as they are, like the catalogs
+func (c *ExtractorClient) Get(ctx context.Context, url string) ([]byte, error) {
+	return c.retry.do(ctx, url, c.sleep, func() ([]byte, error) {
+		resp, err := c.doGet(ctx, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		return resp.body, nil
+	})
+}
+
+// Validators identify the version of a stored response, to make the next request for it conditional
+type Validators struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+// ConditionalResponse is the answer to GetIfModified
+type ConditionalResponse struct {
+	// NotModified is set when the stored version is still current; Body is then empty
+	NotModified bool
+	Body        []byte
+	// Validators identify the version received, or the stored one when not modified
+	Validators Validators
+}
+
+// GetIfModified is Get sending the validators of the stored version of url, so that a server
+// supporting ETag or Last-Modified answers 304 instead of sending the same body again
+func (c *ExtractorClient) GetIfModified(ctx context.Context, url string, stored Validators) (*ConditionalResponse, error) {
+	conditions := http.Header{}
+	if stored.ETag != "" {
+		conditions.Set("If-None-Match", stored.ETag)
+	}
+	if stored.LastModified != "" {
+		conditions.Set("If-Modified-Since", stored.LastModified)
+	}
+	var last *response
+	_, err := c.retry.do(ctx, url, c.sleep, func() ([]byte, error) {
+		resp, err := c.doGet(ctx, url, conditions)
+		if err != nil {
+			return nil, err
+		}
+		last = resp
+		return resp.body, nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	var resp entities.Response
-	if err := json.Unmarshal(body, &resp); err != nil {
+	if last.status == http.StatusNotModified {
+		return &ConditionalResponse{NotModified: true, Validators: stored}, nil
+	}
+	return &ConditionalResponse{
+		Body:       last.body,
+		Validators: Validators{ETag: last.header.Get("ETag"), LastModified: last.header.Get("Last-Modified")},
+	}, nil
+}
+
+// guardedJSON is getJSON behind the circuit of endpoint, failing with a *CircuitOpenError
+// without calling url while the circuit is open
+func (c *ExtractorClient) guardedJSON(ctx context.Context, endpoint, url string, v any) error {
+	done, err := c.breaker.allow(endpoint, url)
+	if err != nil {
+		return err
+	}
+	err = c.getJSON(ctx, endpoint, url, v)
+	done(ctx, err)
+	return err
+}
+
+// getJSON decodes the body of a successful response of endpoint into v; failures are *HTTPError.
+// Retryable failures are retried according to the retry policy.
+func (c *ExtractorClient) getJSON(ctx context.Context, endpoint, url string, v any) error {
+	body, err := c.Get(ctx, url)
+	if err != nil {
+		return err
+	}
+	return c.Decode(endpoint, url, body, v)
+}
+
+// Decode decodes body, the response of endpoint to url, into v, checking it against the schema of v
+// when the client has a schema report; failures are *HTTPError with ErrDecode
+func (c *ExtractorClient) Decode(endpoint, url string, body []byte, v any) error {
+	if c.schema != nil {
+		if drifts, err := schema.Check(body, v); err == nil {
+			c.schema.Record(endpoint, drifts)
+			for _, d := range drifts {
+				if d.Breaking() {
+					return &HTTPError{Kind: ErrDecode, URL: url, StatusCode: http.StatusOK, Body: truncate(body), Cause: fmt.Errorf("%w: %s", schema.ErrRequiredFieldMissing, d)}
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return &HTTPError{Kind: ErrDecode, URL: url, StatusCode: http.StatusOK, Body: truncate(body), Cause: err}
+	}
+	return nil
+}
+
+// response is what get keeps of an HTTP response
+type response struct {
+	status int
+	header http.Header
+	body   []byte
+	// cookies were sent with the request
+	cookies string
+}
+
+// doGet is an internal helper for GET requests, returning 2xx and 304 responses.
+// Cookies rejected with 401 or 403 are invalidated and the request is retried once with new ones.
+func (c *ExtractorClient) doGet(ctx context.Context, url string, extra http.Header) (*response, error) {
+	resp, err := c.get(ctx, url, extra)
+	if err != nil {
 		return nil, err
 	}
-	return &resp, nil
+	if !isAuthFailure(resp.status) {
+		return checkStatus(url, resp)
+	}
+
+	invalidator, ok := c.credentials.(header.Invalidator)
+	if !ok {
+		return checkStatus(url, resp)
+	}
+	fmt.Printf("🔑 %s rejected the cookies (status %d), refreshing them...\n", url, resp.status)
+	if err := invalidator.Invalidate(resp.cookies); err != nil {
+		httpErr := newHTTPError(url, resp.status, resp.header, resp.body)
+		httpErr.Cause = err
+		return nil, httpErr
+	}
+
+	resp, err = c.get(ctx, url, extra)
+	if err != nil {
+		return nil, err
+	}
+	return checkStatus(url, resp)
+}
+
+func checkStatus(url string, resp *response) (*response, error) {
+	if resp.status == http.StatusNotModified {
+		return resp, nil
+	}
+	if err := newHTTPError(url, resp.status, resp.header, resp.body); err != nil {
+		return nil, err
+	}
+	return resp, nil
 }
 
-// doGet is an internal helper for GET requests
-func (c *ExtractorClient) doGet(url string) ([]byte, error) {
-	req, err := http.NewRequest("GET", url, nil)
+// get sends one GET request with the extra headers, once the rate limiter allows it.
+// The overall timeout starts after the wait for the rate limiter.
+func (c *ExtractorClient) get(ctx context.Context, url string, extra http.Header) (*response, error) {
+	if err := c.limiter.Wait(ctx, url); err != nil {
+		return nil, err
+	}
+	if c.timeouts.Overall > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeouts.Overall)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
-	headers, err := header.GetHeaders(c.cookieManager)
+	// Read first, so that a rejection is reported against the cookies in use when the request left
+	cookies, err := c.credentials.GetCookies()
+	if err != nil {
+		return nil, err
+	}
+	headers, err := header.GetHeaders(c.credentials)
 	if err != nil {
 		return nil, err
 	}
 	for k, v := range headers {
 		req.Header.Add(k, v)
 	}
+	if c.locale != "" {
+		req.Header.Set("Accept-Language", c.locale)
+	}
+	for k, values := range extra {
+		req.Header[k] = values
+	}
 	resp, err := c.client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
-	return io.ReadAll(resp.Body)
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &response{status: resp.StatusCode, header: resp.Header, body: body, cookies: cookies}, nil
+}
+
+func isAuthFailure(status int) bool {
+	return status == http.StatusUnauthorized || status == http.StatusForbidden
 }
@@ -0,0 +1,317 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/paologalligit/go-extractor/header"
+	"github.com/paologalligit/go-extractor/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rotatingProvider hands out token=N cookies, moving to the next token on Invalidate
+type rotatingProvider struct {
+	mu          sync.Mutex
+	token       int
+	invalidated int
+	err         error
+}
+
+func (p *rotatingProvider) GetCookies() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return cookieFor(p.token), nil
+}
+
+func (p *rotatingProvider) Invalidate(rejected string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.invalidated++
+	if p.err != nil {
+		return p.err
+	}
+	if rejected == cookieFor(p.token) {
+		p.token++
+	}
+	return nil
+}
+
+func cookieFor(token int) string {
+	return "token=" + string(rune('0'+token))
+}
+
+func TestExtractorClient_ReAuthentication(t *testing.T) {
+	invalidateErr := errors.New("browser unavailable")
+	tests := []struct {
+		name              string
+		credentials       header.CredentialProvider
+		status            int
+		acceptedToken     string
+		expectsRequests   int
+		expectsInvalidate int
+		expectsErr        error
+	}{
+		{
+			name:              "revoked cookies are refreshed and the request retried",
+			credentials:       &rotatingProvider{},
+			status:            http.StatusUnauthorized,
+			acceptedToken:     "1",
+			expectsRequests:   2,
+			expectsInvalidate: 1,
+		},
+		{
+			name:              "still rejected after the refresh",
+			credentials:       &rotatingProvider{},
+			status:            http.StatusForbidden,
+			acceptedToken:     "never",
+			expectsRequests:   2,
+			expectsInvalidate: 1,
+			expectsErr:        ErrUnauthorized,
+		},
+		{
+			name:              "refresh failure",
+			credentials:       &rotatingProvider{err: invalidateErr},
+			status:            http.StatusUnauthorized,
+			acceptedToken:     "1",
+			expectsRequests:   1,
+			expectsInvalidate: 1,
+			expectsErr:        invalidateErr,
+		},
+		{
+			name:            "provider unable to refresh",
+			credentials:     header.NewStaticProvider("token=0"),
+			status:          http.StatusUnauthorized,
+			acceptedToken:   "1",
+			expectsRequests: 1,
+			expectsErr:      ErrUnauthorized,
+		},
+		{
+			name:            "accepted cookies",
+			credentials:     &rotatingProvider{},
+			status:          http.StatusUnauthorized,
+			acceptedToken:   "0",
+			expectsRequests: 1,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				token, err := r.Cookie("token")
+				if err != nil || token.Value != tc.acceptedToken {
+					w.WriteHeader(tc.status)
+					w.Write([]byte("<html>Access denied</html>"))
+					return
+				}
+				w.Write([]byte(`{"result": {}}`))
+			}))
+			defer server.Close()
+
+			resp, err := New(tc.credentials).CallSeats(context.Background(), server.URL)
+
+			assert.Equal(t, tc.expectsRequests, requests)
+			if p, ok := tc.credentials.(*rotatingProvider); ok {
+				assert.Equal(t, tc.expectsInvalidate, p.invalidated)
+			}
+			if tc.expectsErr != nil {
+				assert.ErrorIs(t, err, tc.expectsErr)
+				var httpErr *HTTPError
+				require.ErrorAs(t, err, &httpErr)
+				assert.Equal(t, server.URL, httpErr.URL)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotNil(t, resp)
+		})
+	}
+}
+
+func TestExtractorClient_StatusErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		body          string
+		expectsErr    error
+		expectsStatus int
+	}{
+		{name: "ok", status: http.StatusOK, body: `{"result": {}}`},
+		{name: "session not found", status: http.StatusNotFound, body: "Not Found", expectsErr: ErrNotFound, expectsStatus: 404},
+		{name: "rate limited", status: http.StatusTooManyRequests, body: "slow down", expectsErr: ErrRateLimited, expectsStatus: 429},
+		{name: "server down", status: http.StatusBadGateway, body: "<html>Bad Gateway</html>", expectsErr: ErrServer, expectsStatus: 502},
+		{name: "other client error", status: http.StatusBadRequest, body: "bad", expectsErr: ErrUnexpectedStatus, expectsStatus: 400},
+		{name: "malformed json", status: http.StatusOK, body: "<html>maintenance</html>", expectsErr: ErrDecode, expectsStatus: 200},
+		{name: "long body is truncated", status: http.StatusInternalServerError, body: strings.Repeat("x", 2*maxErrorBody), expectsErr: ErrServer, expectsStatus: 500},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.status)
+				w.Write([]byte(tc.body))
+			}))
+			defer server.Close()
+
+			_, err := NewWithOptions(header.NoAuthProvider{}, Options{}).CallSeats(context.Background(), server.URL+"/seats")
+			if tc.expectsErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tc.expectsErr)
+			var httpErr *HTTPError
+			require.ErrorAs(t, err, &httpErr)
+			assert.Equal(t, server.URL+"/seats", httpErr.URL)
+			assert.Equal(t, tc.expectsStatus, httpErr.StatusCode)
+			assert.LessOrEqual(t, len(httpErr.Body), maxErrorBody+len("…"))
+			assert.True(t, strings.HasPrefix(tc.body, strings.TrimSuffix(httpErr.Body, "…")))
+		})
+	}
+}
+
+func TestExtractorClient_Timeouts(t *testing.T) {
+	tests := []struct {
+		name     string
+		timeouts Timeouts
+		ctx      func() (context.Context, context.CancelFunc)
+	}{
+		{
+			name:     "read timeout",
+			timeouts: Timeouts{Read: 50 * time.Millisecond},
+			ctx:      func() (context.Context, context.CancelFunc) { return context.Background(), func() {} },
+		},
+		{
+			name:     "overall timeout",
+			timeouts: Timeouts{Overall: 50 * time.Millisecond},
+			ctx:      func() (context.Context, context.CancelFunc) { return context.Background(), func() {} },
+		},
+		{
+			name: "caller deadline reaches the transport",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 50*time.Millisecond)
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			release := make(chan struct{})
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// A stuck server: no answer until the test ends
+				select {
+				case <-release:
+				case <-r.Context().Done():
+				}
+			}))
+			defer server.Close()
+			defer close(release)
+
+			ctx, cancel := tc.ctx()
+			defer cancel()
+			c := NewWithOptions(header.NoAuthProvider{}, Options{Timeouts: tc.timeouts})
+			start := time.Now()
+			_, err := c.CallSeats(ctx, server.URL)
+
+			require.Error(t, err)
+			assert.Less(t, time.Since(start), 5*time.Second)
+		})
+	}
+}
+
+func TestExtractorClient_Locale(t *testing.T) {
+	var languages []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		languages = append(languages, r.Header.Get("Accept-Language"))
+		w.Write([]byte(`{"result": {}}`))
+	}))
+	defer server.Close()
+
+	for _, locale := range []string{"en-GB", ""} {
+		c := NewWithOptions(header.NoAuthProvider{}, Options{Locale: locale})
+		_, err := c.CallSeats(context.Background(), server.URL)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []string{"en-GB", ""}, languages)
+}
+
+func TestExtractorClient_GetIfModified(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` || r.Header.Get("If-Modified-Since") == "Mon, 15 Sep 2025 08:00:00 GMT" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v2"`)
+		w.Header().Set("Last-Modified", "Tue, 16 Sep 2025 08:00:00 GMT")
+		w.Write([]byte(`{"result": []}`))
+	}))
+	defer server.Close()
+	c := NewWithOptions(header.NoAuthProvider{}, Options{})
+
+	tests := []struct {
+		name     string
+		stored   Validators
+		expected *ConditionalResponse
+	}{
+		{
+			name:   "nothing stored",
+			stored: Validators{},
+			expected: &ConditionalResponse{
+				Body:       []byte(`{"result": []}`),
+				Validators: Validators{ETag: `"v2"`, LastModified: "Tue, 16 Sep 2025 08:00:00 GMT"},
+			},
+		},
+		{
+			name:     "etag still current",
+			stored:   Validators{ETag: `"v1"`},
+			expected: &ConditionalResponse{NotModified: true, Validators: Validators{ETag: `"v1"`}},
+		},
+		{
+			name:     "last modified still current",
+			stored:   Validators{LastModified: "Mon, 15 Sep 2025 08:00:00 GMT"},
+			expected: &ConditionalResponse{NotModified: true, Validators: Validators{LastModified: "Mon, 15 Sep 2025 08:00:00 GMT"}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := c.GetIfModified(context.Background(), server.URL, tc.stored)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, resp)
+		})
+	}
+}
+
+func TestExtractorClient_Strict(t *testing.T) {
+	body := `{"result": {"sessionOccupancy": 0.5, "seatRows": [{"columns": [{}]}], "layout": "new"}}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+	report := schema.NewReport()
+	c := NewWithOptions(header.NoAuthProvider{}, Options{Schema: report})
+	assert.Same(t, report, c.Schema())
+
+	// An unknown field is reported, the response is still used
+	resp, err := c.CallSeats(context.Background(), server.URL+"/seats")
+	require.NoError(t, err)
+	assert.Equal(t, 0.5, resp.Result.SessionOccupancy)
+	assert.NoError(t, report.Err())
+
+	// A renamed required field fails the call instead of decoding to zero
+	body = `{"result": {"occupancy": 0.5, "seatRows": [{"columns": [{}]}]}}`
+	_, err = c.CallSeats(context.Background(), server.URL+"/seats")
+	assert.ErrorIs(t, err, ErrDecode)
+	assert.ErrorIs(t, err, schema.ErrRequiredFieldMissing)
+	assert.ErrorContains(t, report.Err(), "seats: missing required field result.sessionOccupancy in 1 of 2 responses")
+
+	// Without a report the response decodes as before
+	_, err = NewWithOptions(header.NoAuthProvider{}, Options{}).CallSeats(context.Background(), server.URL+"/seats")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,160 @@
+package client
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// cinemaPattern finds the cinema id in the showings and seats URLs of the api package
+var cinemaPattern = regexp.MustCompile(`/(?:cinemas|Session)/([^/?]+)`)
+
+// Limits configures a RateLimiter. A zero rate disables the matching bucket.
+type Limits struct {
+	// RPS and Burst bound every request sent by the process
+	RPS   float64
+	Burst int
+	// PerHostRPS and PerHostBurst bound the requests sent to each host
+	PerHostRPS   float64
+	PerHostBurst int
+	// PerCinemaRPS and PerCinemaBurst bound the requests about each cinema
+	PerCinemaRPS   float64
+	PerCinemaBurst int
+}
+
+// RateLimiter is a set of token buckets shared by every client built with it,
+// so that the configured budget is the ceiling on the traffic sent to the site
+type RateLimiter struct {
+	limits Limits
+	global *bucket
+
+	mu      sync.Mutex
+	hosts   map[string]*bucket
+	cinemas map[string]*bucket
+}
+
+func NewRateLimiter(limits Limits) *RateLimiter {
+	return &RateLimiter{
+		limits:  limits,
+		global:  newBucket(limits.RPS, limits.Burst),
+		hosts:   map[string]*bucket{},
+		cinemas: map[string]*bucket{},
+	}
+}
+
+// Wait blocks until a request to rawURL fits in every bucket, or ctx is done. The narrower buckets are
+// waited for first and the global token is taken last, so that a request held back by its cinema or host
+// does not keep a global token: the global pace holds for the requests actually sent.
+func (l *RateLimiter) Wait(ctx context.Context, rawURL string) error {
+	if l == nil {
+		return nil
+	}
+	var buckets []*bucket
+	if u, err := url.Parse(rawURL); err == nil {
+		if l.limits.PerCinemaRPS > 0 {
+			if m := cinemaPattern.FindStringSubmatch(u.Path); m != nil {
+				buckets = append(buckets, l.bucketFor(l.cinemas, m[1], l.limits.PerCinemaRPS, l.limits.PerCinemaBurst))
+			}
+		}
+		if l.limits.PerHostRPS > 0 {
+			buckets = append(buckets, l.bucketFor(l.hosts, u.Host, l.limits.PerHostRPS, l.limits.PerHostBurst))
+		}
+	}
+	buckets = append(buckets, l.global)
+	for i, b := range buckets {
+		if err := b.wait(ctx); err != nil {
+			// The request is not sent: give back the tokens already taken
+			for _, taken := range buckets[:i] {
+				taken.release()
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *RateLimiter) bucketFor(buckets map[string]*bucket, key string, rps float64, burst int) *bucket {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := buckets[key]
+	if !ok {
+		b = newBucket(rps, burst)
+		buckets[key] = b
+	}
+	return b
+}
+
+// bucket is a token bucket refilled at rate tokens per second up to burst tokens.
+// A nil bucket never waits.
+type bucket struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+	// now and sleep are replaced in tests
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+func newBucket(rps float64, burst int) *bucket {
+	if rps <= 0 {
+		return nil
+	}
+	b := &bucket{rate: rps, burst: float64(max(burst, 1)), now: time.Now, sleep: sleepContext}
+	b.tokens = b.burst
+	b.last = b.now()
+	return b
+}
+
+// wait takes a token, sleeping until one is available. Tokens are reserved in order,
+// so concurrent callers are spaced out instead of waking up together.
+func (b *bucket) wait(ctx context.Context) error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	now := b.now()
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens--
+	var delay time.Duration
+	if b.tokens < 0 {
+		delay = time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+	b.mu.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+	if err := b.sleep(ctx, delay); err != nil {
+		// Give the reserved token back: the request is not sent
+		b.release()
+		return err
+	}
+	return nil
+}
+
+// release gives back a token taken by wait
+func (b *bucket) release() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = min(b.burst, b.tokens+1)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,149 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock advances when a bucket sleeps, recording each wait
+type fakeClock struct {
+	now    time.Time
+	slept  []time.Duration
+	cancel bool
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	if c.cancel {
+		return context.Canceled
+	}
+	c.slept = append(c.slept, d)
+	c.now = c.now.Add(d)
+	return nil
+}
+
+func fakeBucket(clock *fakeClock, rps float64, burst int) *bucket {
+	b := newBucket(rps, burst)
+	b.now, b.sleep = clock.Now, clock.Sleep
+	b.last = clock.now
+	return b
+}
+
+func TestBucket_Wait(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, 9, 15, 20, 0, 0, 0, time.UTC)}
+	b := fakeBucket(clock, 2, 3)
+
+	for range 5 {
+		require.NoError(t, b.wait(context.Background()))
+	}
+	// The burst leaves at once, then requests are spaced 1/rps apart
+	assert.Equal(t, []time.Duration{500 * time.Millisecond, 500 * time.Millisecond}, clock.slept)
+
+	// An idle bucket refills up to the burst only
+	clock.slept = nil
+	clock.now = clock.now.Add(time.Hour)
+	for range 4 {
+		require.NoError(t, b.wait(context.Background()))
+	}
+	assert.Equal(t, []time.Duration{500 * time.Millisecond}, clock.slept)
+}
+
+func TestBucket_CancelledWaitReturnsToken(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, 9, 15, 20, 0, 0, 0, time.UTC)}
+	b := fakeBucket(clock, 1, 1)
+	require.NoError(t, b.wait(context.Background()))
+
+	clock.cancel = true
+	assert.ErrorIs(t, b.wait(context.Background()), context.Canceled)
+
+	clock.cancel = false
+	require.NoError(t, b.wait(context.Background()))
+	assert.Equal(t, []time.Duration{time.Second}, clock.slept)
+}
+
+func TestRateLimiter_Wait(t *testing.T) {
+	tests := []struct {
+		name           string
+		limits         Limits
+		urls           []string
+		expectsHosts   int
+		expectsCinemas []string
+	}{
+		{
+			name:   "global limit only",
+			limits: Limits{RPS: 10, Burst: 10},
+			urls:   []string{"https://www.thespacecinema.it/api/microservice/booking/Session/1018/42/seats"},
+		},
+		{
+			name:   "per host and per cinema buckets",
+			limits: Limits{PerHostRPS: 5, PerHostBurst: 5, PerCinemaRPS: 1, PerCinemaBurst: 1},
+			urls: []string{
+				"https://www.thespacecinema.it/api/microservice/booking/Session/1018/42/seats",
+				"https://www.thespacecinema.it/api/microservice/showings/cinemas/1020/films?showingDate=",
+				"https://www.thespacecinema.it/api/microservice/showings/films",
+				"http://localhost:8080/api/microservice/showings/cinemas",
+			},
+			expectsHosts:   2,
+			expectsCinemas: []string{"1018", "1020"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := NewRateLimiter(tc.limits)
+			for _, u := range tc.urls {
+				require.NoError(t, l.Wait(context.Background(), u))
+			}
+			assert.Len(t, l.hosts, tc.expectsHosts)
+			var cinemas []string
+			for id := range l.cinemas {
+				cinemas = append(cinemas, id)
+			}
+			assert.ElementsMatch(t, tc.expectsCinemas, cinemas)
+		})
+	}
+}
+
+func TestRateLimiter_GlobalPaceWithCinemaLimits(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, 9, 15, 20, 0, 0, 0, time.UTC)}
+	l := NewRateLimiter(Limits{RPS: 10, Burst: 1, PerCinemaRPS: 1, PerCinemaBurst: 1})
+	l.global = fakeBucket(clock, 10, 1)
+	l.cinemas["1018"] = fakeBucket(clock, 1, 1)
+	l.cinemas["1020"] = fakeBucket(clock, 1, 1)
+	seats := func(cinema string) string {
+		return "https://www.thespacecinema.it/api/microservice/booking/Session/" + cinema + "/42/seats"
+	}
+
+	// The second request waits a second for its cinema: the next one, to another cinema,
+	// still leaves a tenth of a second after it
+	var sent []time.Time
+	for _, cinema := range []string{"1018", "1018", "1020"} {
+		require.NoError(t, l.Wait(context.Background(), seats(cinema)))
+		sent = append(sent, clock.now)
+	}
+	for i := 1; i < len(sent); i++ {
+		assert.GreaterOrEqual(t, sent[i].Sub(sent[i-1]), 100*time.Millisecond, "request %d", i)
+	}
+
+	// A request given up while waiting for the global token gives its cinema token back
+	clock.now = clock.now.Add(time.Minute)
+	require.NoError(t, l.Wait(context.Background(), seats("1018")))
+	clock.cancel = true
+	assert.ErrorIs(t, l.Wait(context.Background(), seats("1020")), context.Canceled)
+	clock.cancel = false
+	clock.slept = nil
+	clock.now = clock.now.Add(time.Second / 10)
+	require.NoError(t, l.Wait(context.Background(), seats("1020")))
+	assert.Empty(t, clock.slept, "the cinema token is still there")
+}
+
+func TestRateLimiter_Nil(t *testing.T) {
+	var l *RateLimiter
+	assert.NoError(t, l.Wait(context.Background(), "https://www.thespacecinema.it"))
+	assert.NoError(t, NewRateLimiter(Limits{}).Wait(context.Background(), "https://www.thespacecinema.it"))
+}
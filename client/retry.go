@@ -0,0 +1,103 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy retries the API calls failing with a retryable error:
+// ErrServer, ErrRateLimited and transport failures
+type RetryPolicy struct {
+	// MaxAttempts counts the first call; 1 or less disables retries
+	MaxAttempts int
+	// BaseDelay is the wait before the first retry, doubled at each attempt
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff and the Retry-After waits
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy matches the requests.retry defaults of the config
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   500 * time.Millisecond,
+		MaxDelay:    30 * time.Second,
+	}
+}
+
+// Retryable reports whether a call failing with err may succeed if repeated. Failures that would
+// happen again, like cookies that cannot be collected or a cassette without the response, are not retried.
+func Retryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return errors.Is(httpErr.Kind, ErrServer) || errors.Is(httpErr.Kind, ErrRateLimited)
+	}
+	return transportFailure(err)
+}
+
+// transportFailure reports whether err is the network failing: a timeout, a connection refused or reset,
+// or a response cut short
+func transportFailure(err error) bool {
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	// http.Client wraps every failure of the round trip in a *url.Error, itself a net.Error: look past it
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// do calls call until it succeeds, fails with a non retryable error, or the policy gives up.
+// No retry is started that would end after the deadline of ctx.
+func (p RetryPolicy) do(ctx context.Context, url string, sleep func(context.Context, time.Duration) error, call func() ([]byte, error)) ([]byte, error) {
+	for attempt := 1; ; attempt++ {
+		body, err := call()
+		if err == nil || attempt >= p.MaxAttempts || ctx.Err() != nil || !Retryable(err) {
+			return body, err
+		}
+
+		wait := p.backoff(attempt)
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) && httpErr.RetryAfter > 0 {
+			wait = min(httpErr.RetryAfter, p.MaxDelay)
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+			return nil, fmt.Errorf("giving up after %d attempts, a retry would end past the deadline: %w", attempt, err)
+		}
+		fmt.Printf("🔁 GET %s failed (attempt %d/%d), retrying in %s: %v\n", url, attempt, p.MaxAttempts, wait.Round(time.Millisecond), err)
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// backoff returns the wait after the given failed attempt: BaseDelay doubled at each attempt,
+// capped at MaxDelay, randomised between half and the full value to spread concurrent retries
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for range attempt - 1 {
+		if delay >= p.MaxDelay {
+			break
+		}
+		delay *= 2
+	}
+	delay = min(delay, p.MaxDelay)
+	if delay <= 0 {
+		return 0
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1))
+}
@@ -0,0 +1,194 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/paologalligit/go-extractor/header"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractorClient_Retry(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: 100 * time.Millisecond, MaxDelay: 2 * time.Second}
+	tests := []struct {
+		name            string
+		policy          RetryPolicy
+		statuses        []int
+		retryAfter      string
+		timeout         time.Duration
+		expectsRequests int
+		expectsWaits    []time.Duration
+		expectsErr      error
+	}{
+		{
+			name:            "transient server error recovered",
+			policy:          policy,
+			statuses:        []int{502, 200},
+			expectsRequests: 2,
+			expectsWaits:    []time.Duration{100 * time.Millisecond},
+		},
+		{
+			name:            "gives up after max attempts",
+			policy:          policy,
+			statuses:        []int{500, 500, 500, 200},
+			expectsRequests: 3,
+			expectsWaits:    []time.Duration{100 * time.Millisecond, 200 * time.Millisecond},
+			expectsErr:      ErrServer,
+		},
+		{
+			name:            "not found is not retried",
+			policy:          policy,
+			statuses:        []int{404, 200},
+			expectsRequests: 1,
+			expectsErr:      ErrNotFound,
+		},
+		{
+			name:            "retry after seconds honoured on 429",
+			policy:          policy,
+			statuses:        []int{429, 200},
+			retryAfter:      "1",
+			expectsRequests: 2,
+			expectsWaits:    []time.Duration{time.Second},
+		},
+		{
+			name:            "retry after capped by max delay on 503",
+			policy:          policy,
+			statuses:        []int{503, 200},
+			retryAfter:      "120",
+			expectsRequests: 2,
+			expectsWaits:    []time.Duration{2 * time.Second},
+		},
+		{
+			name:            "retry past the deadline is not attempted",
+			policy:          RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Second},
+			statuses:        []int{500, 200},
+			timeout:         500 * time.Millisecond,
+			expectsRequests: 1,
+			expectsErr:      ErrServer,
+		},
+		{
+			name:            "retries disabled",
+			policy:          RetryPolicy{},
+			statuses:        []int{500, 200},
+			expectsRequests: 1,
+			expectsErr:      ErrServer,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				status := tc.statuses[requests]
+				requests++
+				if tc.retryAfter != "" {
+					w.Header().Set("Retry-After", tc.retryAfter)
+				}
+				w.WriteHeader(status)
+				w.Write([]byte(`{"result": {}}`))
+			}))
+			defer server.Close()
+
+			var waits []time.Duration
+			c := NewWithOptions(header.NoAuthProvider{}, Options{Retry: tc.policy})
+			c.sleep = func(ctx context.Context, d time.Duration) error {
+				waits = append(waits, d)
+				return nil
+			}
+			ctx := context.Background()
+			if tc.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tc.timeout)
+				defer cancel()
+			}
+
+			_, err := c.CallSeats(ctx, server.URL)
+
+			assert.Equal(t, tc.expectsRequests, requests)
+			require.Len(t, waits, len(tc.expectsWaits))
+			for i, expected := range tc.expectsWaits {
+				if tc.retryAfter != "" {
+					assert.Equal(t, expected, waits[i])
+				} else {
+					// Jitter keeps the wait between half and the full backoff
+					assert.GreaterOrEqual(t, waits[i], expected/2)
+					assert.LessOrEqual(t, waits[i], expected)
+				}
+			}
+			if tc.expectsErr != nil {
+				assert.ErrorIs(t, err, tc.expectsErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	// roundTrip fails like http.Client does when the transport returns err
+	roundTrip := func(err error) error {
+		return &url.Error{Op: "Get", URL: "https://www.thespacecinema.it/api/microservice/showings/films", Err: err}
+	}
+	tests := []struct {
+		name    string
+		err     error
+		expects bool
+	}{
+		{name: "server error", err: &HTTPError{Kind: ErrServer, StatusCode: 502}, expects: true},
+		{name: "rate limited", err: &HTTPError{Kind: ErrRateLimited, StatusCode: 429}, expects: true},
+		{name: "not found", err: &HTTPError{Kind: ErrNotFound, StatusCode: 404}},
+		{name: "connection refused", err: roundTrip(&net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}), expects: true},
+		{name: "connection reset", err: fmt.Errorf("read body: %w", syscall.ECONNRESET), expects: true},
+		{name: "response cut short", err: io.ErrUnexpectedEOF, expects: true},
+		{name: "attempt timeout", err: roundTrip(context.DeadlineExceeded), expects: true},
+		{name: "cancelled", err: roundTrip(context.Canceled)},
+		{name: "circuit open", err: &CircuitOpenError{Circuit: "seats@test"}},
+		{name: "cookies unavailable", err: errors.New("could not start playwright")},
+		{name: "response missing from the cassette", err: roundTrip(errors.New("cassette has no recorded response for GET /films"))},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expects, Retryable(tc.err))
+		})
+	}
+}
+
+// failingProvider cannot collect cookies
+type failingProvider struct {
+	calls int
+}
+
+func (p *failingProvider) GetCookies() (string, error) {
+	p.calls++
+	return "", errors.New("could not start playwright")
+}
+
+func TestExtractorClient_CookiesFailureNotRetried(t *testing.T) {
+	provider := &failingProvider{}
+	c := NewWithOptions(provider, Options{Retry: RetryPolicy{MaxAttempts: 3}})
+
+	_, err := c.CallSeats(context.Background(), "https://www.thespacecinema.it/api/microservice/booking/Session/1030/1/seats")
+
+	assert.Error(t, err)
+	assert.Equal(t, 1, provider.calls)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 9, 19, 20, 0, 0, 0, time.UTC)
+	assert.Equal(t, 30*time.Second, parseRetryAfter("30", now))
+	assert.Equal(t, 90*time.Second, parseRetryAfter("Fri, 19 Sep 2025 20:01:30 GMT", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("Fri, 19 Sep 2025 19:00:00 GMT", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("soon", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("", now))
+}
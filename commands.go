@@ -0,0 +1,777 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/paologalligit/go-extractor/backfill"
+	"github.com/paologalligit/go-extractor/catalog"
+	"github.com/paologalligit/go-extractor/client"
+	"github.com/paologalligit/go-extractor/config"
+	"github.com/paologalligit/go-extractor/export"
+	"github.com/paologalligit/go-extractor/fakeapi"
+	"github.com/paologalligit/go-extractor/fetchshowings"
+	"github.com/paologalligit/go-extractor/header"
+	"github.com/paologalligit/go-extractor/persistence"
+	"github.com/paologalligit/go-extractor/plan"
+	"github.com/paologalligit/go-extractor/schema"
+	"github.com/paologalligit/go-extractor/serve"
+	"github.com/paologalligit/go-extractor/settimers"
+	"github.com/paologalligit/go-extractor/site"
+	"gopkg.in/yaml.v3"
+)
+
+// addRequestFlags registers --workers, --rps, --burst and --strict as overrides of the requests config section
+func addRequestFlags(fs *flag.FlagSet, cf *configFlags) {
+	def := config.Default()
+	workers := fs.Int("workers", def.Requests.Workers, "Number of concurrent workers")
+	rps := fs.Float64("rps", def.Requests.RateLimit.RPS, "Maximum requests per second to the site, 0 for no limit")
+	burst := fs.Int("burst", def.Requests.RateLimit.Burst, "Requests allowed at once above the --rps pace")
+	cf.override("workers", func(cfg *config.Config) {
+		cfg.Requests.Workers = *workers
+	})
+	cf.override("rps", func(cfg *config.Config) {
+		cfg.Requests.RateLimit.RPS = *rps
+	})
+	cf.override("burst", func(cfg *config.Config) {
+		cfg.Requests.RateLimit.Burst = *burst
+	})
+	strict := fs.Bool("strict", def.Requests.Strict, "Check every API response against the expected schema and fail when a required field is missing")
+	cf.override("strict", func(cfg *config.Config) {
+		cfg.Requests.Strict = *strict
+	})
+}
+
+// addSiteFlag registers --site, restricting a command to one of the configured sites
+func addSiteFlag(fs *flag.FlagSet) *string {
+	return fs.String("site", "", "Only run this site (default every configured site)")
+}
+
+// selectSites returns the configured sites, or only the one named by --site
+func selectSites(cfg *config.Config, name string) ([]site.Site, error) {
+	sites, err := cfg.SiteList()
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		return sites, nil
+	}
+	names := make([]string, 0, len(sites))
+	for _, s := range sites {
+		if s.Name == name {
+			return []site.Site{s}, nil
+		}
+		names = append(names, s.Name)
+	}
+	return nil, usageErrorf("--site %q is not configured, the sites are %v", name, names)
+}
+
+// siteFilesPath returns the directory holding the cinemas and films of a site: files.path/<site>,
+// except for the default site which keeps the files written before sites existed, like its cookie cache
+func siteFilesPath(cfg *config.Config, s site.Site) string {
+	if s.Name == site.DefaultName {
+		return cfg.Files.Path
+	}
+	return filepath.Join(cfg.Files.Path, s.Name)
+}
+
+// newAPIClients builds the clients of sites with their credentials. They share one rate limiter,
+// so that the configured budget bounds the traffic of the whole process.
+func newAPIClients(cfg *config.Config, sites []site.Site) ([]*client.ExtractorClient, func(), error) {
+	return siteClients(sites, apiClientOptions(cfg), func(s site.Site) (header.CredentialProvider, error) {
+		return newCredentialProvider(cfg, s)
+	})
+}
+
+// siteClients builds one client per site from opts, sending the locale of the site and keeping its own
+// schema report when opts has one, and returns the function releasing their credentials
+func siteClients(sites []site.Site, opts client.Options, credentialsOf func(site.Site) (header.CredentialProvider, error)) ([]*client.ExtractorClient, func(), error) {
+	var opened []header.CredentialProvider
+	release := func() {
+		for _, credentials := range opened {
+			closeCredentials(credentials)
+		}
+	}
+	clients := make([]*client.ExtractorClient, 0, len(sites))
+	for _, s := range sites {
+		credentials, err := credentialsOf(s)
+		if err != nil {
+			release()
+			return nil, nil, err
+		}
+		opened = append(opened, credentials)
+		siteOpts := opts
+		siteOpts.Locale = s.Locale
+		if opts.Schema != nil {
+			siteOpts.Schema = schema.NewReport()
+		}
+		clients = append(clients, client.NewWithOptions(credentials, siteOpts))
+	}
+	return clients, release, nil
+}
+
+// apiClientOptions converts the requests config section for the client
+func apiClientOptions(cfg *config.Config) client.Options {
+	limits := cfg.Requests.RateLimit
+	opts := client.Options{
+		Retry: retryPolicy(cfg),
+		Limiter: client.NewRateLimiter(client.Limits{
+			RPS:            limits.RPS,
+			Burst:          limits.Burst,
+			PerHostRPS:     limits.PerHostRPS,
+			PerHostBurst:   limits.PerHostBurst,
+			PerCinemaRPS:   limits.PerCinemaRPS,
+			PerCinemaBurst: limits.PerCinemaBurst,
+		}),
+		Timeouts: client.Timeouts{
+			Connect: cfg.Requests.Timeout.Connect.Duration(),
+			Read:    cfg.Requests.Timeout.Read.Duration(),
+			Overall: cfg.Requests.Timeout.Overall.Duration(),
+		},
+		Breaker: client.BreakerSettings{
+			Threshold: cfg.Requests.CircuitBreaker.Threshold,
+			Cooldown:  cfg.Requests.CircuitBreaker.Cooldown.Duration(),
+			PerCinema: cfg.Requests.CircuitBreaker.PerCinema,
+		},
+	}
+	if cfg.Requests.Strict {
+		opts.Schema = schema.NewReport()
+	}
+	return opts
+}
+
+func newSettimersOptions(cfg *config.Config, s site.Site, c *client.ExtractorClient, p persistence.Persistence) *settimers.SettimersOptions {
+	return &settimers.SettimersOptions{
+		Client:        c,
+		Persistence:   p,
+		MaxGoroutines: cfg.Requests.Workers,
+		Site:          s,
+		FilesPath:     siteFilesPath(cfg, s),
+		CatalogTTL:    cfg.Files.CatalogTTL.Duration(),
+		Sampling:      cfg.Sampling,
+	}
+}
+
+// reportSchema prints the schema drifts seen by the client of each site in a run,
+// and returns an error when a required field went missing
+func reportSchema(sites []site.Site, clients []*client.ExtractorClient) error {
+	var errs []error
+	for i, c := range clients {
+		report := c.Schema()
+		if report.HasDrifts() {
+			fmt.Printf("🧬 Schema drifts of %s:\n", sites[i].Name)
+			report.Print(os.Stdout)
+		}
+		if err := report.Err(); err != nil {
+			errs = append(errs, fmt.Errorf("schema of %s: %w", sites[i].Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// circuitsInterval is how often track and serve list the circuits that are not closed
+const circuitsInterval = time.Minute
+
+// watchCircuits lists the open and half-open circuits of the clients every circuitsInterval, until ctx is done
+func watchCircuits(ctx context.Context, clients []*client.ExtractorClient) {
+	ticker := time.NewTicker(circuitsInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, c := range clients {
+				client.PrintCircuits(os.Stdout, c.Circuits())
+			}
+		}
+	}
+}
+
+// retryPolicy converts requests.retry for the client
+func retryPolicy(cfg *config.Config) client.RetryPolicy {
+	return client.RetryPolicy{
+		MaxAttempts: cfg.Requests.Retry.MaxAttempts,
+		BaseDelay:   cfg.Requests.Retry.BaseDelay.Duration(),
+		MaxDelay:    cfg.Requests.Retry.MaxDelay.Duration(),
+	}
+}
+
+func fetchCommand() *command {
+	return &command{
+		name:    "fetch",
+		aliases: []string{"all"},
+		summary: "Fetch all showings with their seat counts and write them to a file",
+		usage:   "fetch [--site NAME] [--workers N] [--rps N] [--burst N] [--strict] [--output FILE] [--record DIR | --replay DIR] [--config FILE]",
+		setup: func(fs *flag.FlagSet) runFunc {
+			cf := newConfigFlags(fs)
+			addRequestFlags(fs, cf)
+			tf := newCassetteFlags(fs)
+			siteName := addSiteFlag(fs)
+			output := fs.String("output", "", "Output file, with a single site (default showings_SITE_YYYYMMDD_HHMMSS.json)")
+			return func(ctx context.Context, args []string) error {
+				if len(args) > 0 {
+					return usageErrorf("unexpected arguments: %v", args)
+				}
+				cfg, err := cf.load()
+				if err != nil {
+					return err
+				}
+				sites, err := selectSites(cfg, *siteName)
+				if err != nil {
+					return err
+				}
+				if *output != "" && len(sites) > 1 {
+					return usageErrorf("--output needs a single site, pick one with --site")
+				}
+
+				clients, release, err := tf.newClients(cfg, sites)
+				if err != nil {
+					return err
+				}
+				defer release()
+				fmt.Printf("Configuration: Using %d workers with at most %g requests per second (burst %d)\n", cfg.Requests.Workers, cfg.Requests.RateLimit.RPS, cfg.Requests.RateLimit.Burst)
+
+				timestamp := time.Now().Format("20060102_150405")
+				for i, s := range sites {
+					filename := *output
+					if filename == "" {
+						filename = fmt.Sprintf("showings_%s_%s.json", s.Name, timestamp)
+					}
+					opt := &fetchshowings.FetchShowingsOptions{
+						Client:         clients[i],
+						MaxGoroutines:  cfg.Requests.Workers,
+						Site:           s,
+						FilesPath:      siteFilesPath(cfg, s),
+						CatalogTTL:     cfg.Files.CatalogTTL.Duration(),
+						OutputFileName: filename,
+					}
+					fmt.Printf("🌍 Fetching %s\n", s.Name)
+					if err := fetchshowings.RunFetchShowings(ctx, opt); err != nil {
+						return errors.Join(fmt.Errorf("error running fetch showings of %s: %w", s.Name, err), reportSchema(sites, clients))
+					}
+				}
+				return reportSchema(sites, clients)
+			}
+		},
+	}
+}
+
+func catalogCommand() *command {
+	return &command{
+		name:    "catalog",
+		summary: "Refresh the stored cinemas and films, whatever their age",
+		usage:   "catalog refresh [--site NAME] [--strict] [--config FILE]",
+		setup: func(fs *flag.FlagSet) runFunc {
+			cf := newConfigFlags(fs)
+			addRequestFlags(fs, cf)
+			siteName := addSiteFlag(fs)
+			return func(ctx context.Context, args []string) error {
+				if len(args) != 1 || args[0] != "refresh" {
+					return usageErrorf("expected subcommand: refresh")
+				}
+				cfg, err := cf.load()
+				if err != nil {
+					return err
+				}
+				sites, err := selectSites(cfg, *siteName)
+				if err != nil {
+					return err
+				}
+				clients, release, err := newAPIClients(cfg, sites)
+				if err != nil {
+					return err
+				}
+				defer release()
+
+				for i, s := range sites {
+					cache := catalog.New(siteFilesPath(cfg, s), cfg.Files.CatalogTTL.Duration(), clients[i], s.Endpoints())
+					for _, name := range catalog.Names {
+						status, err := cache.Refresh(ctx, name)
+						if err != nil {
+							return errors.Join(fmt.Errorf("error refreshing the %s of %s: %w", name, s.Name, err), reportSchema(sites, clients))
+						}
+						fmt.Printf("📚 %s %s: %s\n", s.Name, name, status)
+					}
+				}
+				return reportSchema(sites, clients)
+			}
+		},
+	}
+}
+
+func trackCommand() *command {
+	return &command{
+		name:    "track",
+		aliases: []string{"today"},
+		summary: "Schedule a seat count sample for each of today's sessions and store it in Postgres",
+		usage:   "track [--site NAME] [--date YYYY-MM-DD] [--workers N] [--rps N] [--burst N] [--strict] [--record DIR | --replay DIR] [--config FILE]",
+		setup: func(fs *flag.FlagSet) runFunc {
+			cf := newConfigFlags(fs)
+			addRequestFlags(fs, cf)
+			tf := newCassetteFlags(fs)
+			siteName := addSiteFlag(fs)
+			date := fs.String("date", "", "Showing date to track (default today in the time zone of each site, the recorded day with --replay)")
+			return func(ctx context.Context, args []string) error {
+				if len(args) > 0 {
+					return usageErrorf("unexpected arguments: %v", args)
+				}
+				if *date != "" {
+					if _, err := time.Parse("2006-01-02", *date); err != nil {
+						return usageErrorf("--date must be YYYY-MM-DD, got %q", *date)
+					}
+				}
+				cfg, err := cf.load()
+				if err != nil {
+					return err
+				}
+				sites, err := selectSites(cfg, *siteName)
+				if err != nil {
+					return err
+				}
+
+				pool, err := persistence.NewPostgresPool(ctx, cfg.Database.URL)
+				if err != nil {
+					return fmt.Errorf("error creating postgres pool: %w", err)
+				}
+				defer pool.Close()
+				fmt.Println("Postgres pool created...")
+
+				clients, release, err := tf.newClients(cfg, sites)
+				if err != nil {
+					return err
+				}
+				defer release()
+
+				// The sites are tracked side by side, each on today in its time zone.
+				// A replay goes through the recorded day on a fast-forwarding clock.
+				p := persistence.NewPostgresPersistence(pool)
+				days := make([]time.Time, len(sites))
+				opts := make([]*settimers.SettimersOptions, len(sites))
+				for i, s := range sites {
+					opts[i] = newSettimersOptions(cfg, s, clients[i], p)
+					days[i] = s.Today(time.Now())
+					if *date != "" {
+						days[i], _ = time.ParseInLocation("2006-01-02", *date, s.Location)
+					}
+					if tf.replayer != nil {
+						day, clock, err := tf.replayDay(s, *date)
+						if err != nil {
+							return err
+						}
+						days[i], opts[i].Now, opts[i].Delay = day, clock.Now, clock.After
+					}
+				}
+				watchCtx, stopWatch := context.WithCancel(ctx)
+				defer stopWatch()
+				go watchCircuits(watchCtx, clients)
+				var wg sync.WaitGroup
+				errs := make([]error, len(sites))
+				for i, s := range sites {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						if err := settimers.RunDay(ctx, opts[i], days[i]); err != nil {
+							errs[i] = fmt.Errorf("error running seat timers of %s: %w", s.Name, err)
+						}
+					}()
+				}
+				wg.Wait()
+				return errors.Join(append(errs, reportSchema(sites, clients))...)
+			}
+		},
+	}
+}
+
+func serveCommand() *command {
+	return &command{
+		name:    "serve",
+		summary: "Run the daily tracking cycle as a long-running daemon",
+		usage:   "serve [--site NAME] [--build-at HH:MM] [--workers N] [--rps N] [--burst N] [--strict] [--config FILE]",
+		setup: func(fs *flag.FlagSet) runFunc {
+			cf := newConfigFlags(fs)
+			addRequestFlags(fs, cf)
+			siteName := addSiteFlag(fs)
+			buildAt := fs.String("build-at", config.Default().Serve.BuildAt, "Time of the site's time zone at which each day's session list is built")
+			cf.override("build-at", func(cfg *config.Config) {
+				cfg.Serve.BuildAt = *buildAt
+			})
+			return func(ctx context.Context, args []string) error {
+				if len(args) > 0 {
+					return usageErrorf("unexpected arguments: %v", args)
+				}
+				cfg, err := cf.load()
+				if err != nil {
+					return err
+				}
+				sites, err := selectSites(cfg, *siteName)
+				if err != nil {
+					return err
+				}
+
+				pool, err := persistence.NewPostgresPool(ctx, cfg.Database.URL)
+				if err != nil {
+					return fmt.Errorf("error creating postgres pool: %w", err)
+				}
+				defer pool.Close()
+				fmt.Println("Postgres pool created...")
+
+				clients, release, err := newAPIClients(cfg, sites)
+				if err != nil {
+					return err
+				}
+				defer release()
+
+				opt := &serve.ServeOptions{Serve: cfg.Serve}
+				p := persistence.NewPostgresPersistence(pool)
+				for i, s := range sites {
+					opt.Sites = append(opt.Sites, newSettimersOptions(cfg, s, clients[i], p))
+				}
+				watchCtx, stopWatch := context.WithCancel(ctx)
+				defer stopWatch()
+				go watchCircuits(watchCtx, clients)
+				if err := serve.RunServe(ctx, opt); err != nil {
+					return errors.Join(fmt.Errorf("error running serve: %w", err), reportSchema(sites, clients))
+				}
+				return reportSchema(sites, clients)
+			}
+		},
+	}
+}
+
+func planCommand() *command {
+	return &command{
+		name:    "plan",
+		summary: "Show when each of the day's sessions would be sampled, without sampling any seat",
+		usage:   "plan [--site NAME] [--date YYYY-MM-DD] [--format table|json] [--workers N] [--rps N] [--burst N] [--strict] [--config FILE]",
+		setup: func(fs *flag.FlagSet) runFunc {
+			cf := newConfigFlags(fs)
+			addRequestFlags(fs, cf)
+			siteName := addSiteFlag(fs)
+			date := fs.String("date", "", "Showing date to plan (default today in the time zone of each site)")
+			format := fs.String("format", plan.FormatTable, "Output format: table or json")
+			return func(ctx context.Context, args []string) error {
+				if len(args) > 0 {
+					return usageErrorf("unexpected arguments: %v", args)
+				}
+				if *format != plan.FormatTable && *format != plan.FormatJSON {
+					return usageErrorf("--format must be %s or %s, got %q", plan.FormatTable, plan.FormatJSON, *format)
+				}
+				if *date != "" {
+					if _, err := time.Parse("2006-01-02", *date); err != nil {
+						return usageErrorf("--date must be YYYY-MM-DD, got %q", *date)
+					}
+				}
+				cfg, err := cf.load()
+				if err != nil {
+					return err
+				}
+				sites, err := selectSites(cfg, *siteName)
+				if err != nil {
+					return err
+				}
+
+				// Cookies are only needed by the sites whose sessions file has to be fetched
+				days := make([]time.Time, len(sites))
+				var toFetch []site.Site
+				for i, s := range sites {
+					days[i] = s.Today(time.Now())
+					if *date != "" {
+						days[i], _ = time.ParseInLocation("2006-01-02", *date, s.Location)
+					}
+					if plan.NeedsFetch(s.Name, days[i]) {
+						toFetch = append(toFetch, s)
+					}
+				}
+				clients, release, err := newAPIClients(cfg, toFetch)
+				if err != nil {
+					return err
+				}
+				defer release()
+
+				for i, s := range sites {
+					opt := &plan.PlanOptions{
+						MaxGoroutines: cfg.Requests.Workers,
+						Site:          s,
+						FilesPath:     siteFilesPath(cfg, s),
+						CatalogTTL:    cfg.Files.CatalogTTL.Duration(),
+						Sampling:      cfg.Sampling,
+						Day:           days[i],
+						Format:        *format,
+						Output:        os.Stdout,
+					}
+					if j := slices.IndexFunc(toFetch, func(f site.Site) bool { return f.Name == s.Name }); j >= 0 {
+						opt.Client = clients[j]
+					}
+					if err := plan.RunPlan(ctx, opt); err != nil {
+						return errors.Join(fmt.Errorf("error running plan of %s: %w", s.Name, err), reportSchema(sites, clients))
+					}
+				}
+				return reportSchema(sites, clients)
+			}
+		},
+	}
+}
+
+func exportCommand() *command {
+	return &command{
+		name:    "export",
+		summary: "Export the collected seat counts as CSV, NDJSON or JSON",
+		usage:   "export [--from YYYY-MM-DD] [--to YYYY-MM-DD] [--site NAME] [--cinema NAME] [--film NAME] [--session ID] [--format csv|ndjson|json] [--output FILE] [--log FILE] [--config FILE]",
+		setup: func(fs *flag.FlagSet) runFunc {
+			cf := newConfigFlags(fs)
+			from := fs.String("from", "", "First day to export, by logged time (inclusive)")
+			to := fs.String("to", "", "Last day to export, by logged time (inclusive)")
+			siteName := fs.String("site", "", "Only export this site")
+			cinema := fs.String("cinema", "", "Only export this cinema name (case insensitive)")
+			film := fs.String("film", "", "Only export this film name (case insensitive)")
+			session := fs.String("session", "", "Only export this session id")
+			format := fs.String("format", export.FormatCSV, "Output format: csv, ndjson or json")
+			output := fs.String("output", "", "Output file (default stdout)")
+			logFile := fs.String("log", "", "Read from this NDJSON seat counts log instead of Postgres")
+			return func(ctx context.Context, args []string) error {
+				if len(args) > 0 {
+					return usageErrorf("unexpected arguments: %v", args)
+				}
+				if !slices.Contains(export.Formats, *format) {
+					return usageErrorf("--format must be one of %v, got %q", export.Formats, *format)
+				}
+				filter := persistence.SessionFilter{
+					Site:       *siteName,
+					CinemaName: *cinema,
+					FilmName:   *film,
+					SessionId:  *session,
+				}
+				if *from != "" {
+					day, err := time.ParseInLocation("2006-01-02", *from, time.Local)
+					if err != nil {
+						return usageErrorf("--from must be YYYY-MM-DD, got %q", *from)
+					}
+					filter.From = day
+				}
+				if *to != "" {
+					day, err := time.ParseInLocation("2006-01-02", *to, time.Local)
+					if err != nil {
+						return usageErrorf("--to must be YYYY-MM-DD, got %q", *to)
+					}
+					filter.To = day.AddDate(0, 0, 1)
+				}
+				cfg, err := cf.load()
+				if err != nil {
+					return err
+				}
+
+				var reader persistence.Reader
+				if *logFile != "" {
+					reader = persistence.NewFilePersistence(*logFile)
+				} else {
+					pool, err := persistence.NewPostgresPool(ctx, cfg.Database.URL)
+					if err != nil {
+						return fmt.Errorf("error creating postgres pool: %w", err)
+					}
+					defer pool.Close()
+					reader = persistence.NewPostgresPersistence(pool)
+				}
+
+				out := os.Stdout
+				if *output != "" {
+					file, err := os.Create(*output)
+					if err != nil {
+						return fmt.Errorf("error creating output file: %w", err)
+					}
+					defer file.Close()
+					out = file
+				}
+				w := bufio.NewWriter(out)
+				count, err := export.RunExport(ctx, &export.ExportOptions{
+					Reader: reader,
+					Filter: filter,
+					Format: *format,
+					Output: w,
+				})
+				if err != nil {
+					return err
+				}
+				if err := w.Flush(); err != nil {
+					return fmt.Errorf("error writing export: %w", err)
+				}
+				// stdout may carry the data, keep the summary on stderr
+				fmt.Fprintf(os.Stderr, "Exported %d seat counts\n", count)
+				return nil
+			}
+		},
+	}
+}
+
+func importCommand() *command {
+	return &command{
+		name:    "import",
+		summary: "Backfill Postgres from seat counts logs and showings files",
+		usage:   "import [--config FILE] PATH...",
+		setup: func(fs *flag.FlagSet) runFunc {
+			cf := newConfigFlags(fs)
+			return func(ctx context.Context, args []string) error {
+				if len(args) == 0 {
+					return usageErrorf("expected at least one file or directory to import")
+				}
+				cfg, err := cf.load()
+				if err != nil {
+					return err
+				}
+				pool, err := persistence.NewPostgresPool(ctx, cfg.Database.URL)
+				if err != nil {
+					return fmt.Errorf("error creating postgres pool: %w", err)
+				}
+				defer pool.Close()
+
+				summary, err := backfill.RunImport(ctx, &backfill.ImportOptions{
+					Importer: persistence.NewPostgresPersistence(pool),
+					Paths:    args,
+				})
+				if summary != nil {
+					for _, file := range summary.Files {
+						if file.Err != nil {
+							fmt.Printf("❌ %s: %v\n", file.Path, file.Err)
+							continue
+						}
+						fmt.Printf("%s: %d inserted, %d skipped, %d malformed\n", file.Path, file.Inserted, file.Skipped, file.Malformed)
+					}
+					fmt.Printf("\n🏁 Total: %d inserted, %d skipped (already stored), %d malformed\n", summary.Inserted, summary.Skipped, summary.Malformed)
+				}
+				if err != nil {
+					return fmt.Errorf("error importing: %w", err)
+				}
+				return nil
+			}
+		},
+	}
+}
+
+func initdbCommand() *command {
+	return &command{
+		name:    "initdb",
+		summary: "Create the Postgres schema from db/schema.sql",
+		usage:   "initdb [--config FILE]",
+		setup: func(fs *flag.FlagSet) runFunc {
+			cf := newConfigFlags(fs)
+			return func(ctx context.Context, args []string) error {
+				if len(args) > 0 {
+					return usageErrorf("unexpected arguments: %v", args)
+				}
+				cfg, err := cf.load()
+				if err != nil {
+					return err
+				}
+				pool, err := persistence.NewPostgresPool(ctx, cfg.Database.URL)
+				if err != nil {
+					return fmt.Errorf("error creating postgres pool: %w", err)
+				}
+				defer pool.Close()
+				if err := persistence.InitPostgresSchema(ctx, pool); err != nil {
+					return fmt.Errorf("error initializing postgres schema: %w", err)
+				}
+				fmt.Println("Postgres schema initialized")
+				return nil
+			}
+		},
+	}
+}
+
+func configCommand() *command {
+	return &command{
+		name:    "config",
+		summary: "Show the effective configuration after merging file, env vars and defaults",
+		usage:   "config print [--format yaml|json] [--config FILE]",
+		setup: func(fs *flag.FlagSet) runFunc {
+			cf := newConfigFlags(fs)
+			format := fs.String("format", "yaml", "Output format: yaml or json")
+			return func(ctx context.Context, args []string) error {
+				if len(args) != 1 || args[0] != "print" {
+					return usageErrorf("expected subcommand: print")
+				}
+				if *format != "yaml" && *format != "json" {
+					return usageErrorf("--format must be yaml or json, got %q", *format)
+				}
+				cfg, err := cf.load()
+				if err != nil {
+					return err
+				}
+				// Never print the database password
+				if u, err := url.Parse(cfg.Database.URL); err == nil && cfg.Database.URL != "" {
+					cfg.Database.URL = u.Redacted()
+				}
+
+				if *format == "json" {
+					enc := json.NewEncoder(os.Stdout)
+					enc.SetIndent("", "  ")
+					return enc.Encode(cfg)
+				}
+				enc := yaml.NewEncoder(os.Stdout)
+				enc.SetIndent(2)
+				defer enc.Close()
+				return enc.Encode(cfg)
+			}
+		},
+	}
+}
+
+func fakeapiCommand() *command {
+	return &command{
+		name:    "fakeapi",
+		summary: "Serve a fake cinema API from a scenario file, for end-to-end tests",
+		usage:   "fakeapi --scenario FILE [--addr HOST:PORT]",
+		setup: func(fs *flag.FlagSet) runFunc {
+			scenarioFile := fs.String("scenario", "", "Scenario file with the cinemas, films, sessions and faults to serve")
+			addr := fs.String("addr", "localhost:8089", "Address to listen on")
+			return func(ctx context.Context, args []string) error {
+				if len(args) > 0 {
+					return usageErrorf("unexpected arguments: %v", args)
+				}
+				if *scenarioFile == "" {
+					return usageErrorf("--scenario is required")
+				}
+				scenario, err := fakeapi.LoadScenario(*scenarioFile)
+				if err != nil {
+					return usageErrorf("%v", err)
+				}
+				handler, err := fakeapi.New(scenario)
+				if err != nil {
+					return err
+				}
+				listener, err := net.Listen("tcp", *addr)
+				if err != nil {
+					return fmt.Errorf("error listening on %s: %w", *addr, err)
+				}
+				server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					fmt.Printf("%s %s %s\n", handler.Now().Format("15:04:05"), r.Method, r.URL)
+					handler.ServeHTTP(w, r)
+				})}
+				go func() {
+					<-ctx.Done()
+					shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+					defer cancel()
+					server.Shutdown(shutdownCtx)
+				}()
+
+				fmt.Printf("🎭 Fake API serving %s on http://%s\n", *scenarioFile, listener.Addr())
+				fmt.Printf("Point the tool at it with GOEXTRACTOR_API_BASE_URL=http://%s GOEXTRACTOR_AUTH_PROVIDER=none\n", listener.Addr())
+				if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					return fmt.Errorf("error serving the fake API: %w", err)
+				}
+				return nil
+			}
+		},
+	}
+}
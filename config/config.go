@@ -0,0 +1,442 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/paologalligit/go-extractor/site"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultPath is the config file loaded when no path is given and GOEXTRACTOR_CONFIG is not set
+const DefaultPath = "go-extractor.yaml"
+
+// Config is the full set of runtime options.
+// Values are layered: defaults, then the config file, then env vars, then command line flags.
+type Config struct {
+	API      API      `yaml:"api" json:"api"`
+	Sites    []Site   `yaml:"sites,omitempty" json:"sites,omitempty"`
+	Auth     Auth     `yaml:"auth" json:"auth"`
+	Files    Files    `yaml:"files" json:"files"`
+	Database Database `yaml:"database" json:"database"`
+	Requests Requests `yaml:"requests" json:"requests"`
+	Sampling Sampling `yaml:"sampling" json:"sampling"`
+	Serve    Serve    `yaml:"serve" json:"serve"`
+}
+
+// API configures the cinema website tracked when no sites are listed
+type API struct {
+	BaseURL  string `yaml:"base_url" json:"base_url" env:"GOEXTRACTOR_API_BASE_URL"`
+	Locale   string `yaml:"locale" json:"locale" env:"GOEXTRACTOR_API_LOCALE"`
+	Timezone string `yaml:"timezone" json:"timezone" env:"GOEXTRACTOR_API_TIMEZONE"`
+	Currency string `yaml:"currency" json:"currency" env:"GOEXTRACTOR_API_CURRENCY"`
+	// AuthURL is the page opened to collect the cookies, defaults to BaseURL
+	AuthURL string `yaml:"auth_url" json:"auth_url" env:"GOEXTRACTOR_API_AUTH_URL"`
+}
+
+// Site configures one of the cinema websites tracked in a run. Listing sites replaces the api section.
+type Site struct {
+	// Name tags every record stored for the site
+	Name     string `yaml:"name" json:"name"`
+	BaseURL  string `yaml:"base_url" json:"base_url"`
+	Locale   string `yaml:"locale" json:"locale"`
+	Timezone string `yaml:"timezone" json:"timezone"`
+	Currency string `yaml:"currency" json:"currency"`
+	AuthURL  string `yaml:"auth_url" json:"auth_url"`
+}
+
+// siteNamePattern keeps site names usable in file names
+var siteNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// SiteList returns the sites to track: the listed sites, or the one described by the api section
+func (c *Config) SiteList() ([]site.Site, error) {
+	sites := c.Sites
+	if len(sites) == 0 {
+		sites = []Site{{
+			Name:     site.DefaultName,
+			BaseURL:  c.API.BaseURL,
+			Locale:   c.API.Locale,
+			Timezone: c.API.Timezone,
+			Currency: c.API.Currency,
+			AuthURL:  c.API.AuthURL,
+		}}
+	}
+	var result []site.Site
+	for _, s := range sites {
+		loc, err := time.LoadLocation(s.Timezone)
+		if err != nil || s.Timezone == "" {
+			return nil, fmt.Errorf("site %s: timezone must be an IANA time zone like Europe/Rome, got %q", s.Name, s.Timezone)
+		}
+		authURL := s.AuthURL
+		if authURL == "" {
+			authURL = s.BaseURL
+		}
+		result = append(result, site.Site{
+			Name:     s.Name,
+			BaseURL:  s.BaseURL,
+			Locale:   s.Locale,
+			Location: loc,
+			Currency: s.Currency,
+			AuthURL:  authURL,
+		})
+	}
+	return result, nil
+}
+
+// Auth providers
+const (
+	AuthPlaywright = "playwright"
+	AuthStatic     = "static"
+	AuthNone       = "none"
+)
+
+// Auth configures how requests to the API are authenticated
+type Auth struct {
+	// Provider is playwright (headless browser), static (fixed cookie string) or none
+	Provider string `yaml:"provider" json:"provider" env:"GOEXTRACTOR_AUTH_PROVIDER"`
+	// CookiesFile holds the cookie string of the static provider
+	CookiesFile string `yaml:"cookies_file" json:"cookies_file" env:"GOEXTRACTOR_AUTH_COOKIES_FILE"`
+	// CookiesEnv names the env var holding the cookie string of the static provider, when CookiesFile is empty
+	CookiesEnv string `yaml:"cookies_env" json:"cookies_env" env:"GOEXTRACTOR_AUTH_COOKIES_ENV"`
+	// CacheFile keeps the playwright cookies across runs; empty disables the cache
+	CacheFile string `yaml:"cache_file" json:"cache_file" env:"GOEXTRACTOR_AUTH_CACHE_FILE"`
+	// RefreshBefore refreshes the playwright cookies in the background this long before they expire; zero disables it
+	RefreshBefore Duration `yaml:"refresh_before" json:"refresh_before" env:"GOEXTRACTOR_AUTH_REFRESH_BEFORE"`
+	// TokenRefresh renews the playwright cookies by exchanging their refresh token over HTTP, before falling back to the browser.
+	// Off by default: the refresh endpoint (api.Endpoints.RefreshToken) is not confirmed on the live site yet.
+	TokenRefresh bool `yaml:"token_refresh" json:"token_refresh" env:"GOEXTRACTOR_AUTH_TOKEN_REFRESH"`
+}
+
+// defaultCacheFile returns the cookie cache path under the user cache dir, or "" when there is none
+func defaultCacheFile() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "go-extractor", "cookies.json")
+}
+
+// Files configures where cinema and film catalogs are stored
+type Files struct {
+	Path string `yaml:"path" json:"path" env:"GOEXTRACTOR_FILES_PATH"`
+	// CatalogTTL is how long the stored cinemas and films are used before asking the API again; zero asks on every run
+	CatalogTTL Duration `yaml:"catalog_ttl" json:"catalog_ttl" env:"GOEXTRACTOR_FILES_CATALOG_TTL"`
+}
+
+// Database configures the Postgres connection
+type Database struct {
+	URL string `yaml:"url" json:"url" env:"DATABASE_URL"`
+}
+
+// Requests configures the worker pools calling the API
+type Requests struct {
+	Workers   int       `yaml:"workers" json:"workers" env:"GOEXTRACTOR_WORKERS"`
+	RateLimit RateLimit `yaml:"rate_limit" json:"rate_limit"`
+	Retry     Retry     `yaml:"retry" json:"retry"`
+	Timeout   Timeout   `yaml:"timeout" json:"timeout"`
+	// CircuitBreaker stops calling a failing endpoint for a while
+	CircuitBreaker CircuitBreaker `yaml:"circuit_breaker" json:"circuit_breaker"`
+	// Strict checks every response against the entities, reporting unknown, missing and retyped fields,
+	// and fails the calls whose response lacks a required field
+	Strict bool `yaml:"strict" json:"strict" env:"GOEXTRACTOR_STRICT"`
+}
+
+// CircuitBreaker opens the circuit of the showings or seats endpoint after Threshold consecutive failed calls,
+// rejecting the calls for Cooldown before letting one probe through. A zero Threshold disables it.
+type CircuitBreaker struct {
+	Threshold int      `yaml:"threshold" json:"threshold" env:"GOEXTRACTOR_CIRCUIT_BREAKER_THRESHOLD"`
+	Cooldown  Duration `yaml:"cooldown" json:"cooldown" env:"GOEXTRACTOR_CIRCUIT_BREAKER_COOLDOWN"`
+	// PerCinema gives each cinema its own circuit, so one failing cinema does not stop the others
+	PerCinema bool `yaml:"per_cinema" json:"per_cinema" env:"GOEXTRACTOR_CIRCUIT_BREAKER_PER_CINEMA"`
+}
+
+// Timeout bounds each attempt of an API call, so a stuck request never blocks a worker.
+// Zero disables the matching timeout.
+type Timeout struct {
+	// Connect bounds the TCP connection and the TLS handshake
+	Connect Duration `yaml:"connect" json:"connect" env:"GOEXTRACTOR_TIMEOUT_CONNECT"`
+	// Read bounds the wait for the response headers once the request is sent
+	Read Duration `yaml:"read" json:"read" env:"GOEXTRACTOR_TIMEOUT_READ"`
+	// Overall bounds an attempt from the connection to the end of the body
+	Overall Duration `yaml:"overall" json:"overall" env:"GOEXTRACTOR_TIMEOUT_OVERALL"`
+}
+
+// RateLimit caps the traffic sent to the site, whatever the number of workers and timers.
+// A zero rate disables the matching limit.
+type RateLimit struct {
+	// RPS and Burst bound every request of the process
+	RPS   float64 `yaml:"rps" json:"rps" env:"GOEXTRACTOR_RATE_LIMIT_RPS"`
+	Burst int     `yaml:"burst" json:"burst" env:"GOEXTRACTOR_RATE_LIMIT_BURST"`
+	// PerHostRPS and PerHostBurst bound the requests to each host
+	PerHostRPS   float64 `yaml:"per_host_rps" json:"per_host_rps" env:"GOEXTRACTOR_RATE_LIMIT_PER_HOST_RPS"`
+	PerHostBurst int     `yaml:"per_host_burst" json:"per_host_burst" env:"GOEXTRACTOR_RATE_LIMIT_PER_HOST_BURST"`
+	// PerCinemaRPS and PerCinemaBurst bound the requests about each cinema
+	PerCinemaRPS   float64 `yaml:"per_cinema_rps" json:"per_cinema_rps" env:"GOEXTRACTOR_RATE_LIMIT_PER_CINEMA_RPS"`
+	PerCinemaBurst int     `yaml:"per_cinema_burst" json:"per_cinema_burst" env:"GOEXTRACTOR_RATE_LIMIT_PER_CINEMA_BURST"`
+}
+
+// Retry configures how API calls failing with a server error, a 429 or a network error are retried
+type Retry struct {
+	// MaxAttempts counts the first call; 1 disables retries
+	MaxAttempts int `yaml:"max_attempts" json:"max_attempts" env:"GOEXTRACTOR_RETRY_MAX_ATTEMPTS"`
+	// BaseDelay is the wait before the first retry, doubled at each attempt with jitter
+	BaseDelay Duration `yaml:"base_delay" json:"base_delay" env:"GOEXTRACTOR_RETRY_BASE_DELAY"`
+	// MaxDelay caps the backoff and the Retry-After waits
+	MaxDelay Duration `yaml:"max_delay" json:"max_delay" env:"GOEXTRACTOR_RETRY_MAX_DELAY"`
+}
+
+// Sampling configures when a session's seats are sampled relative to its start time
+type Sampling struct {
+	// Offset is added to the session start time to get the sampling time
+	Offset Duration `yaml:"offset" json:"offset" env:"GOEXTRACTOR_SAMPLING_OFFSET"`
+	// JitterMin and JitterMax bound the random delay added on top of Offset
+	JitterMin Duration `yaml:"jitter_min" json:"jitter_min" env:"GOEXTRACTOR_SAMPLING_JITTER_MIN"`
+	JitterMax Duration `yaml:"jitter_max" json:"jitter_max" env:"GOEXTRACTOR_SAMPLING_JITTER_MAX"`
+	// RolloverHour: sessions starting before this hour belong to the night after the showing date
+	RolloverHour int `yaml:"rollover_hour" json:"rollover_hour" env:"GOEXTRACTOR_SAMPLING_ROLLOVER_HOUR"`
+	// CinemaOffsets overrides Offset for specific cinemas, by site name then cinema id:
+	// the same id can be another cinema on another site. The offsets listed for a site replace its defaults.
+	CinemaOffsets map[string]map[string]Duration `yaml:"cinema_offsets" json:"cinema_offsets"`
+	// Deadline bounds a sample including its retries: a later seat count is dropped, not recorded as on time
+	Deadline Duration `yaml:"deadline" json:"deadline" env:"GOEXTRACTOR_SAMPLING_DEADLINE"`
+	// MaxDefer bounds how long a sample rejected by an open circuit waits for it to let calls through again
+	MaxDefer Duration `yaml:"max_defer" json:"max_defer" env:"GOEXTRACTOR_SAMPLING_MAX_DEFER"`
+}
+
+// OffsetFor returns the sampling offset for the given cinema of siteName
+func (s Sampling) OffsetFor(siteName, cinemaId string) time.Duration {
+	if offset, ok := s.CinemaOffsets[siteName][cinemaId]; ok {
+		return offset.Duration()
+	}
+	return s.Offset.Duration()
+}
+
+// Serve configures the daily cycle of the long-running serve command
+type Serve struct {
+	// BuildAt is the local "HH:MM" time at which the day's session list is built
+	BuildAt string `yaml:"build_at" json:"build_at" env:"GOEXTRACTOR_SERVE_BUILD_AT"`
+	// RetryInterval is the wait before retrying a failed build of the session list
+	RetryInterval Duration `yaml:"retry_interval" json:"retry_interval" env:"GOEXTRACTOR_SERVE_RETRY_INTERVAL"`
+}
+
+// BuildTime returns the time at which the session list for day is built
+func (s Serve) BuildTime(day time.Time) (time.Time, error) {
+	clock, err := time.Parse("15:04", s.BuildAt)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), 0, 0, day.Location()), nil
+}
+
+// Default returns the built-in configuration
+func Default() *Config {
+	return &Config{
+		API: API{
+			BaseURL:  "https://www.thespacecinema.it/",
+			Locale:   "it-IT",
+			Timezone: "Europe/Rome",
+			Currency: "EUR",
+		},
+		Auth: Auth{
+			Provider:      AuthPlaywright,
+			CookiesEnv:    "GOEXTRACTOR_COOKIES",
+			CacheFile:     defaultCacheFile(),
+			RefreshBefore: Duration(5 * time.Minute),
+		},
+		Files: Files{
+			Path:       "files",
+			CatalogTTL: Duration(24 * time.Hour),
+		},
+		Requests: Requests{
+			Workers: 10,
+			RateLimit: RateLimit{
+				RPS:   10,
+				Burst: 10,
+			},
+			Retry: Retry{
+				MaxAttempts: 3,
+				BaseDelay:   Duration(500 * time.Millisecond),
+				MaxDelay:    Duration(30 * time.Second),
+			},
+			Timeout: Timeout{
+				Connect: Duration(10 * time.Second),
+				Read:    Duration(30 * time.Second),
+				Overall: Duration(time.Minute),
+			},
+			CircuitBreaker: CircuitBreaker{
+				Threshold: 5,
+				Cooldown:  Duration(30 * time.Second),
+			},
+		},
+		Sampling: Sampling{
+			Offset:       Duration(12 * time.Minute),
+			JitterMin:    Duration(100 * time.Millisecond),
+			JitterMax:    Duration(2 * time.Minute),
+			RolloverHour: 6,
+			Deadline:     Duration(time.Minute),
+			MaxDefer:     Duration(30 * time.Minute),
+			CinemaOffsets: map[string]map[string]Duration{
+				site.DefaultName: {
+					// Torino closes the seat map before the session starts
+					"1018": Duration(-2 * time.Minute),
+				},
+			},
+		},
+		Serve: Serve{
+			BuildAt:       "08:00",
+			RetryInterval: Duration(10 * time.Minute),
+		},
+	}
+}
+
+// Load builds the configuration from the defaults, the config file at path and the environment.
+// An empty path falls back to GOEXTRACTOR_CONFIG, then to DefaultPath if that file exists.
+// Flags are applied by the caller on the returned config, before calling Validate.
+func Load(path string) (*Config, error) {
+	_ = godotenv.Load() // Load .env if present, ignore error
+
+	cfg := Default()
+
+	required := path != ""
+	if path == "" {
+		path = os.Getenv("GOEXTRACTOR_CONFIG")
+		required = path != ""
+	}
+	if path == "" {
+		path = DefaultPath
+	}
+	if err := cfg.loadFile(path, required); err != nil {
+		return nil, err
+	}
+	if err := applyEnv(cfg, os.LookupEnv); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) loadFile(path string, required bool) error {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) && !required {
+			return nil
+		}
+		return fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer file.Close()
+
+	dec := yaml.NewDecoder(file)
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate checks that every option holds a usable value
+func (c *Config) Validate() error {
+	var errs []error
+	if !isAbsoluteURL(c.API.BaseURL) {
+		errs = append(errs, fmt.Errorf("api.base_url must be an absolute http(s) URL, got %q", c.API.BaseURL))
+	}
+	names := make(map[string]bool)
+	for i, s := range c.Sites {
+		if !siteNamePattern.MatchString(s.Name) {
+			errs = append(errs, fmt.Errorf("sites[%d].name must be made of letters, digits, - and _, got %q", i, s.Name))
+		} else if names[s.Name] {
+			errs = append(errs, fmt.Errorf("sites[%d].name %q is used by another site", i, s.Name))
+		}
+		names[s.Name] = true
+		if !isAbsoluteURL(s.BaseURL) {
+			errs = append(errs, fmt.Errorf("sites[%d].base_url must be an absolute http(s) URL, got %q", i, s.BaseURL))
+		}
+		if s.AuthURL != "" && !isAbsoluteURL(s.AuthURL) {
+			errs = append(errs, fmt.Errorf("sites[%d].auth_url must be an absolute http(s) URL, got %q", i, s.AuthURL))
+		}
+	}
+	if c.API.AuthURL != "" && !isAbsoluteURL(c.API.AuthURL) {
+		errs = append(errs, fmt.Errorf("api.auth_url must be an absolute http(s) URL, got %q", c.API.AuthURL))
+	}
+	if _, err := c.SiteList(); err != nil {
+		errs = append(errs, err)
+	}
+	switch c.Auth.Provider {
+	case AuthPlaywright, AuthNone:
+	case AuthStatic:
+		if c.Auth.CookiesFile == "" && c.Auth.CookiesEnv == "" {
+			errs = append(errs, errors.New("auth.cookies_file or auth.cookies_env is required by the static auth provider"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("auth.provider must be %s, %s or %s, got %q", AuthPlaywright, AuthStatic, AuthNone, c.Auth.Provider))
+	}
+	if c.Auth.RefreshBefore < 0 {
+		errs = append(errs, fmt.Errorf("auth.refresh_before must not be negative, got %s", c.Auth.RefreshBefore))
+	}
+	if c.Files.Path == "" {
+		errs = append(errs, errors.New("files.path must not be empty"))
+	}
+	if c.Files.CatalogTTL < 0 {
+		errs = append(errs, errors.New("files.catalog_ttl must not be negative"))
+	}
+	if c.Requests.Workers <= 0 {
+		errs = append(errs, fmt.Errorf("requests.workers must be positive, got %d", c.Requests.Workers))
+	}
+	limits := c.Requests.RateLimit
+	if limits.RPS < 0 || limits.PerHostRPS < 0 || limits.PerCinemaRPS < 0 {
+		errs = append(errs, fmt.Errorf("requests.rate_limit rates must not be negative, got rps %g, per_host_rps %g, per_cinema_rps %g", limits.RPS, limits.PerHostRPS, limits.PerCinemaRPS))
+	}
+	if limits.Burst < 0 || limits.PerHostBurst < 0 || limits.PerCinemaBurst < 0 {
+		errs = append(errs, fmt.Errorf("requests.rate_limit bursts must not be negative, got burst %d, per_host_burst %d, per_cinema_burst %d", limits.Burst, limits.PerHostBurst, limits.PerCinemaBurst))
+	}
+	if c.Requests.Retry.MaxAttempts <= 0 {
+		errs = append(errs, fmt.Errorf("requests.retry.max_attempts must be positive, got %d", c.Requests.Retry.MaxAttempts))
+	}
+	if c.Requests.Retry.BaseDelay < 0 {
+		errs = append(errs, fmt.Errorf("requests.retry.base_delay must not be negative, got %s", c.Requests.Retry.BaseDelay))
+	}
+	if c.Requests.Retry.MaxDelay < c.Requests.Retry.BaseDelay {
+		errs = append(errs, fmt.Errorf("requests.retry.max_delay (%s) must not be less than requests.retry.base_delay (%s)", c.Requests.Retry.MaxDelay, c.Requests.Retry.BaseDelay))
+	}
+	timeout := c.Requests.Timeout
+	if timeout.Connect < 0 || timeout.Read < 0 || timeout.Overall < 0 {
+		errs = append(errs, fmt.Errorf("requests.timeout must not be negative, got connect %s, read %s, overall %s", timeout.Connect, timeout.Read, timeout.Overall))
+	}
+	if c.Requests.CircuitBreaker.Threshold < 0 {
+		errs = append(errs, fmt.Errorf("requests.circuit_breaker.threshold must not be negative, got %d", c.Requests.CircuitBreaker.Threshold))
+	}
+	if c.Requests.CircuitBreaker.Threshold > 0 && c.Requests.CircuitBreaker.Cooldown <= 0 {
+		errs = append(errs, fmt.Errorf("requests.circuit_breaker.cooldown must be positive, got %s", c.Requests.CircuitBreaker.Cooldown))
+	}
+	if c.Sampling.Deadline < 0 {
+		errs = append(errs, fmt.Errorf("sampling.deadline must not be negative, got %s", c.Sampling.Deadline))
+	}
+	if c.Sampling.MaxDefer < 0 {
+		errs = append(errs, fmt.Errorf("sampling.max_defer must not be negative, got %s", c.Sampling.MaxDefer))
+	}
+	if c.Sampling.JitterMin < 0 {
+		errs = append(errs, fmt.Errorf("sampling.jitter_min must not be negative, got %s", c.Sampling.JitterMin))
+	}
+	if c.Sampling.JitterMax < c.Sampling.JitterMin {
+		errs = append(errs, fmt.Errorf("sampling.jitter_max (%s) must not be less than sampling.jitter_min (%s)", c.Sampling.JitterMax, c.Sampling.JitterMin))
+	}
+	if c.Sampling.RolloverHour < 0 || c.Sampling.RolloverHour > 23 {
+		errs = append(errs, fmt.Errorf("sampling.rollover_hour must be between 0 and 23, got %d", c.Sampling.RolloverHour))
+	}
+	if _, err := c.Serve.BuildTime(time.Now()); err != nil {
+		errs = append(errs, fmt.Errorf("serve.build_at must be a HH:MM time, got %q", c.Serve.BuildAt))
+	}
+	if c.Serve.RetryInterval <= 0 {
+		errs = append(errs, fmt.Errorf("serve.retry_interval must be positive, got %s", c.Serve.RetryInterval))
+	}
+	return errors.Join(errs...)
+}
+
+func isAbsoluteURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
@@ -0,0 +1,229 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestLoad_Layering(t *testing.T) {
+	path := writeConfig(t, `
+api:
+  base_url: http://localhost:8080
+requests:
+  workers: 4
+  rate_limit:
+    rps: 2.5
+sampling:
+  offset: 5m
+  cinema_offsets:
+    thespacecinema:
+      "1030": 1m
+    vue-uk:
+      "1018": 3m
+`)
+	t.Setenv("GOEXTRACTOR_WORKERS", "8")
+	t.Setenv("DATABASE_URL", "postgres://u:p@localhost/db")
+
+	cfg, err := Load(path)
+	require.NoError(t, err)
+
+	// file overrides defaults
+	assert.Equal(t, "http://localhost:8080", cfg.API.BaseURL)
+	assert.Equal(t, 2.5, cfg.Requests.RateLimit.RPS)
+	assert.Equal(t, 5*time.Minute, cfg.Sampling.Offset.Duration())
+	assert.Equal(t, time.Minute, cfg.Sampling.OffsetFor("thespacecinema", "1030"))
+	assert.Equal(t, 3*time.Minute, cfg.Sampling.OffsetFor("vue-uk", "1018"))
+	assert.Equal(t, 5*time.Minute, cfg.Sampling.OffsetFor("thespacecinema", "1018"), "the offsets of a site replace its defaults")
+	// env overrides file
+	assert.Equal(t, 8, cfg.Requests.Workers)
+	assert.Equal(t, "postgres://u:p@localhost/db", cfg.Database.URL)
+	// untouched values keep their defaults
+	assert.Equal(t, "files", cfg.Files.Path)
+	assert.Equal(t, 10, cfg.Requests.RateLimit.Burst)
+	assert.Equal(t, 6, cfg.Sampling.RolloverHour)
+	assert.False(t, cfg.Auth.TokenRefresh, "the refresh endpoint is unconfirmed")
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		env  map[string]string
+	}{
+		{
+			name: "explicit file missing",
+			path: filepath.Join(t.TempDir(), "missing.yaml"),
+		},
+		{
+			name: "unknown field",
+			path: writeConfig(t, "requests:\n  wrokers: 3\n"),
+		},
+		{
+			name: "bad duration in file",
+			path: writeConfig(t, "sampling:\n  offset: twelve\n"),
+		},
+		{
+			name: "bad env value",
+			env:  map[string]string{"GOEXTRACTOR_SAMPLING_OFFSET": "soon"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			_, err := Load(tc.path)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestConfig_SiteList(t *testing.T) {
+	cfg := Default()
+	sites, err := cfg.SiteList()
+	require.NoError(t, err)
+	require.Len(t, sites, 1)
+	assert.Equal(t, "thespacecinema", sites[0].Name)
+	assert.Equal(t, "https://www.thespacecinema.it/", sites[0].AuthURL)
+	assert.Equal(t, "Europe/Rome", sites[0].Location.String())
+
+	path := writeConfig(t, `
+sites:
+  - name: thespacecinema
+    base_url: https://www.thespacecinema.it/
+    locale: it-IT
+    timezone: Europe/Rome
+    currency: EUR
+  - name: vue-uk
+    base_url: https://www.myvue.com/
+    locale: en-GB
+    timezone: Europe/London
+    currency: GBP
+    auth_url: https://www.myvue.com/cinema/london
+`)
+	cfg, err = Load(path)
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+	sites, err = cfg.SiteList()
+	require.NoError(t, err)
+	require.Len(t, sites, 2)
+	assert.Equal(t, "vue-uk", sites[1].Name)
+	assert.Equal(t, "Europe/London", sites[1].Location.String())
+	assert.Equal(t, "GBP", sites[1].Currency)
+	assert.Equal(t, "https://www.myvue.com/cinema/london", sites[1].AuthURL)
+}
+
+func TestSampling_OffsetFor(t *testing.T) {
+	s := Default().Sampling
+	assert.Equal(t, 12*time.Minute, s.OffsetFor("thespacecinema", "1030"))
+	assert.Equal(t, -2*time.Minute, s.OffsetFor("thespacecinema", "1018"))
+	// Cinema ids are only unique within a site
+	assert.Equal(t, 12*time.Minute, s.OffsetFor("vue-uk", "1018"))
+	assert.Equal(t, 12*time.Minute, s.OffsetFor("unknown", "1018"))
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(cfg *Config)
+		wantErr string
+	}{
+		{
+			name:   "defaults are valid",
+			mutate: func(cfg *Config) {},
+		},
+		{
+			name:    "relative base url",
+			mutate:  func(cfg *Config) { cfg.API.BaseURL = "www.thespacecinema.it" },
+			wantErr: "api.base_url",
+		},
+		{
+			name:    "unknown timezone",
+			mutate:  func(cfg *Config) { cfg.API.Timezone = "Europe/Atlantis" },
+			wantErr: "timezone must be an IANA time zone",
+		},
+		{
+			name: "duplicate site names",
+			mutate: func(cfg *Config) {
+				s := Site{Name: "vue", BaseURL: "https://www.myvue.com/", Timezone: "Europe/London"}
+				cfg.Sites = []Site{s, s}
+			},
+			wantErr: `sites[1].name "vue" is used by another site`,
+		},
+		{
+			name: "site name unusable in file names",
+			mutate: func(cfg *Config) {
+				cfg.Sites = []Site{{Name: "../vue", BaseURL: "https://www.myvue.com/", Timezone: "Europe/London"}}
+			},
+			wantErr: "sites[0].name must be made of letters",
+		},
+		{
+			name:    "no workers",
+			mutate:  func(cfg *Config) { cfg.Requests.Workers = 0 },
+			wantErr: "requests.workers",
+		},
+		{
+			name:    "no retry attempt",
+			mutate:  func(cfg *Config) { cfg.Requests.Retry.MaxAttempts = 0 },
+			wantErr: "requests.retry.max_attempts",
+		},
+		{
+			name:    "retry max delay below base delay",
+			mutate:  func(cfg *Config) { cfg.Requests.Retry.MaxDelay = Duration(time.Millisecond) },
+			wantErr: "requests.retry.max_delay",
+		},
+		{
+			name:    "negative read timeout",
+			mutate:  func(cfg *Config) { cfg.Requests.Timeout.Read = Duration(-time.Second) },
+			wantErr: "requests.timeout",
+		},
+		{
+			name:    "negative catalog ttl",
+			mutate:  func(cfg *Config) { cfg.Files.CatalogTTL = Duration(-time.Hour) },
+			wantErr: "files.catalog_ttl must not be negative",
+		},
+		{
+			name:    "circuit breaker without cooldown",
+			mutate:  func(cfg *Config) { cfg.Requests.CircuitBreaker.Cooldown = 0 },
+			wantErr: "requests.circuit_breaker.cooldown must be positive",
+		},
+		{
+			name:    "jitter max below min",
+			mutate:  func(cfg *Config) { cfg.Sampling.JitterMax = Duration(time.Millisecond) },
+			wantErr: "sampling.jitter_max",
+		},
+		{
+			name:    "rollover hour out of range",
+			mutate:  func(cfg *Config) { cfg.Sampling.RolloverHour = 24 },
+			wantErr: "sampling.rollover_hour",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := Default()
+			tc.mutate(cfg)
+			err := cfg.Validate()
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/paologalligit/go-extractor/config"
+)
+
+// configFlags registers the --config flag and the flags overriding config values
+type configFlags struct {
+	fs        *flag.FlagSet
+	path      *string
+	overrides map[string]func(cfg *config.Config)
+}
+
+func newConfigFlags(fs *flag.FlagSet) *configFlags {
+	return &configFlags{
+		fs:        fs,
+		path:      fs.String("config", "", "Config file (default $GOEXTRACTOR_CONFIG or "+config.DefaultPath+")"),
+		overrides: make(map[string]func(cfg *config.Config)),
+	}
+}
+
+// override registers apply to run on the loaded config when the named flag is set
+func (cf *configFlags) override(name string, apply func(cfg *config.Config)) {
+	cf.overrides[name] = apply
+}
+
+// load returns the validated config: defaults, config file, env vars, then the flags set on the command line
+func (cf *configFlags) load() (*config.Config, error) {
+	cfg, err := config.Load(*cf.path)
+	if err != nil {
+		return nil, usageErrorf("%v", err)
+	}
+	cf.fs.Visit(func(f *flag.Flag) {
+		if apply, ok := cf.overrides[f.Name]; ok {
+			apply(cfg)
+		}
+	})
+	if err := cfg.Validate(); err != nil {
+		return nil, usageErrorf("invalid configuration: %v", err)
+	}
+	return cfg, nil
+}
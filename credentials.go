@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/paologalligit/go-extractor/config"
+	"github.com/paologalligit/go-extractor/header"
+	"github.com/paologalligit/go-extractor/site"
+)
+
+// newCredentialProvider builds the provider selected by auth.provider for s.
+// It is only called by the commands that call the API, so the others never start a browser.
+func newCredentialProvider(cfg *config.Config, s site.Site) (header.CredentialProvider, error) {
+	switch cfg.Auth.Provider {
+	case config.AuthStatic:
+		if cfg.Auth.CookiesFile != "" {
+			return header.NewStaticProviderFromFile(cfg.Auth.CookiesFile)
+		}
+		return header.NewStaticProviderFromEnv(cfg.Auth.CookiesEnv)
+	case config.AuthNone:
+		return header.NoAuthProvider{}, nil
+	default:
+		opts := header.CookiesManagerOptions{
+			BaseURL:       s.AuthURL,
+			CacheFile:     siteCacheFile(cfg.Auth.CacheFile, s.Name),
+			RefreshBefore: cfg.Auth.RefreshBefore.Duration(),
+		}
+		if cfg.Auth.TokenRefresh {
+			opts.RefreshURL = s.Endpoints().RefreshToken()
+		}
+		cookiesManager, err := header.NewWithOptions(opts)
+		if err != nil {
+			return nil, fmt.Errorf("error getting cookies of %s: %w", s.Name, err)
+		}
+		return cookiesManager, nil
+	}
+}
+
+// siteCacheFile returns the cookie cache of siteName: cookies.json becomes cookies-<site>.json,
+// except for the default site which keeps the cache written before sites existed
+func siteCacheFile(cacheFile, siteName string) string {
+	if cacheFile == "" || siteName == site.DefaultName {
+		return cacheFile
+	}
+	ext := filepath.Ext(cacheFile)
+	return strings.TrimSuffix(cacheFile, ext) + "-" + siteName + ext
+}
+
+// closeCredentials releases the provider, stopping the background refresh of the playwright cookies
+func closeCredentials(credentials header.CredentialProvider) {
+	if closer, ok := credentials.(io.Closer); ok {
+		closer.Close()
+	}
+}
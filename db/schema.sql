@@ -1,5 +1,6 @@
 CREATE TABLE IF NOT EXISTS session (
     id SERIAL PRIMARY KEY,
+    site TEXT NOT NULL DEFAULT 'thespacecinema',
     cinema_name TEXT NOT NULL,
     film_name TEXT NOT NULL,
     session_id TEXT NOT NULL,
@@ -11,3 +12,25 @@ CREATE TABLE IF NOT EXISTS session (
 CREATE INDEX IF NOT EXISTS idx_session_session_id ON session(session_id);
 CREATE INDEX IF NOT EXISTS idx_session_cinema_name ON session(cinema_name);
 CREATE INDEX IF NOT EXISTS idx_session_film_name ON session(film_name);
+CREATE INDEX IF NOT EXISTS idx_session_session_id_logged_at ON session(session_id, logged_at);
+
+-- Databases created before sites were introduced: their rows belong to the default site
+ALTER TABLE session ADD COLUMN IF NOT EXISTS site TEXT NOT NULL DEFAULT 'thespacecinema';
+CREATE INDEX IF NOT EXISTS idx_session_site ON session(site);
+
+CREATE TABLE IF NOT EXISTS showing (
+    id SERIAL PRIMARY KEY,
+    site TEXT NOT NULL DEFAULT 'thespacecinema',
+    cinema_id TEXT NOT NULL,
+    cinema_name TEXT NOT NULL,
+    film_id TEXT NOT NULL,
+    film_name TEXT NOT NULL,
+    session_id TEXT NOT NULL,
+    start_time TIMESTAMP NOT NULL,
+    seats INTEGER NOT NULL,
+    total_seats INTEGER NOT NULL,
+    fetched_at TIMESTAMPTZ NOT NULL,
+    UNIQUE (site, cinema_id, session_id, fetched_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_showing_session_id ON showing(session_id);
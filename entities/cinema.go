@@ -1,23 +1,23 @@
 package entities
 
 type Cinema struct {
-	CinemaId   string `json:"cinemaId"`
+	CinemaId   string `json:"cinemaId" schema:"required"`
 	CinemaName string `json:"cinemaName"`
 }
 
 type Region struct {
-	Cinemas []Cinema `json:"cinemas"`
+	Cinemas []Cinema `json:"cinemas" schema:"required"`
 }
 
 type CinemasFile struct {
-	Result []Region `json:"result"`
+	Result []Region `json:"result" schema:"required"`
 }
 
 type Platea []SeatRow
 
 type Result struct {
-	SeatRows         Platea  `json:"seatRows"`
-	SessionOccupancy float64 `json:"sessionOccupancy"`
+	SeatRows         Platea  `json:"seatRows" schema:"required"`
+	SessionOccupancy float64 `json:"sessionOccupancy" schema:"required"`
 }
 
 type Seat struct {
@@ -25,7 +25,7 @@ type Seat struct {
 }
 
 type SeatRow struct {
-	Columns []*Seat `json:"columns"`
+	Columns []*Seat `json:"columns" schema:"required"`
 }
 
 func (p *Platea) CountSeats() int {
@@ -5,28 +5,29 @@ import (
 )
 
 type Session struct {
-	SessionId        string `json:"sessionId"`
-	StartHour        string `json:"startHour"`
-	RoundedStartHour string `json:"roundedStartHour"`
-	Seats            int    `json:"seats"`
-	TotalSeats       int    `json:"totalSeats"`
-	StartTime        string `json:"startTime"`
+	SessionId        string `json:"sessionId" schema:"required"`
+	StartHour        string `json:"startHour" schema:"-"`
+	RoundedStartHour string `json:"roundedStartHour" schema:"-"`
+	Seats            int    `json:"seats" schema:"-"`
+	TotalSeats       int    `json:"totalSeats" schema:"-"`
+	StartTime        string `json:"startTime" schema:"required"`
 }
 
 type ShowingGroup struct {
 	Date     string    `json:"date"`
-	Sessions []Session `json:"sessions"`
+	Sessions []Session `json:"sessions" schema:"required"`
 }
 
 type ShowingResponse struct {
 	Result []struct {
-		FilmId        string         `json:"filmId"`
+		FilmId        string         `json:"filmId" schema:"required"`
 		FilmTitle     string         `json:"filmTitle"`
-		ShowingGroups []ShowingGroup `json:"showingGroups"`
-	} `json:"result"`
+		ShowingGroups []ShowingGroup `json:"showingGroups" schema:"required"`
+	} `json:"result" schema:"required"`
 }
 
 type ShowingResult struct {
+	Site          string         `json:"site,omitempty"`
 	Movie         string         `json:"movie"`
 	FilmId        string         `json:"filmId"`
 	CinemaId      string         `json:"cinemaId"`
@@ -38,6 +39,7 @@ type BookingResult struct {
 	Result Result `json:"result"`
 }
 type SeatLogEntry struct {
+	Site       string    `json:"site,omitempty"`
 	CinemaName string    `json:"cinemaName"`
 	FilmName   string    `json:"filmName"`
 	SessionId  string    `json:"sessionId"`
@@ -47,11 +49,11 @@ type SeatLogEntry struct {
 }
 
 type ScheduledSession struct {
-	Session    Session
-	CinemaId   string
-	CinemaName string
-	FilmId     string
-	FilmName   string
+	Session    Session `json:"session"`
+	CinemaId   string  `json:"cinemaId"`
+	CinemaName string  `json:"cinemaName"`
+	FilmId     string  `json:"filmId"`
+	FilmName   string  `json:"filmName"`
 }
 
 type WorkItem struct {
@@ -60,5 +62,35 @@ type WorkItem struct {
 }
 
 type Response struct {
-	Result Result `json:"result"`
+	Result Result `json:"result" schema:"required"`
+}
+
+// Reasons reported by the sampling plan of a session
+const (
+	PlanReasonStarted     = "already started"
+	PlanReasonUnparseable = "unparseable start time"
+	PlanReasonRollover    = "after-midnight rollover"
+)
+
+// PlannedSession is a ScheduledSession with the time its seats will be sampled at
+type PlannedSession struct {
+	ScheduledSession
+	StartAt time.Time     `json:"startAt"`
+	FireAt  time.Time     `json:"fireAt"`
+	Jitter  time.Duration `json:"jitter"`
+	// Skipped sessions get no timer, Reason tells why; rolled over sessions are scheduled with a Reason
+	Skipped bool   `json:"skipped"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// SessionSnapshot is one session of a ShowingResult, with its seat counts as fetched at FetchedAt
+type SessionSnapshot struct {
+	Site       string    `json:"site"`
+	CinemaId   string    `json:"cinemaId"`
+	CinemaName string    `json:"cinemaName"`
+	FilmId     string    `json:"filmId"`
+	FilmName   string    `json:"filmName"`
+	Session    Session   `json:"session"`
+	StartAt    time.Time `json:"startAt"`
+	FetchedAt  time.Time `json:"fetchedAt"`
 }
@@ -1,9 +1,9 @@
 package entities
 
 type Film struct {
-	FilmId string `json:"filmId"`
+	FilmId string `json:"filmId" schema:"required"`
 }
 
 type FilmsFile struct {
-	Result []Film `json:"result"`
+	Result []Film `json:"result" schema:"required"`
 }
@@ -0,0 +1,166 @@
+package export
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/paologalligit/go-extractor/entities"
+	"github.com/paologalligit/go-extractor/persistence"
+)
+
+// Output formats
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+	FormatJSON   = "json"
+)
+
+// Formats lists the supported output formats
+var Formats = []string{FormatCSV, FormatNDJSON, FormatJSON}
+
+// Columns is the CSV header, in the order every format writes the fields
+var Columns = []string{"logged_at", "cinema_name", "film_name", "session_id", "start_hour", "seats", "site"}
+
+type ExportOptions struct {
+	Reader persistence.Reader
+	Filter persistence.SessionFilter
+	Format string
+	Output io.Writer
+}
+
+// entryWriter writes entries one at a time, so exports never hold the whole data set in memory
+type entryWriter interface {
+	Write(entry entities.SeatLogEntry) error
+	Close() error
+}
+
+// RunExport streams the seat log entries selected by options.Filter to options.Output
+// and returns the number of entries written
+func RunExport(ctx context.Context, options *ExportOptions) (int, error) {
+	w, err := newEntryWriter(options.Format, options.Output)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	err = options.Reader.ReadSessionSeats(ctx, options.Filter, func(entry entities.SeatLogEntry) error {
+		count++
+		return w.Write(entry)
+	})
+	if err != nil {
+		return count, fmt.Errorf("error exporting seat log entries: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return count, fmt.Errorf("error finishing export: %w", err)
+	}
+	return count, nil
+}
+
+func newEntryWriter(format string, out io.Writer) (entryWriter, error) {
+	switch format {
+	case FormatCSV:
+		w := csv.NewWriter(out)
+		if err := w.Write(Columns); err != nil {
+			return nil, err
+		}
+		return &csvWriter{w: w}, nil
+	case FormatNDJSON:
+		return &ndjsonWriter{enc: json.NewEncoder(out)}, nil
+	case FormatJSON:
+		return &jsonWriter{out: out}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// exportedEntry fixes the field names and order of the JSON formats to Columns
+type exportedEntry struct {
+	LoggedAt   time.Time `json:"logged_at"`
+	CinemaName string    `json:"cinema_name"`
+	FilmName   string    `json:"film_name"`
+	SessionId  string    `json:"session_id"`
+	StartHour  string    `json:"start_hour"`
+	Seats      int       `json:"seats"`
+	Site       string    `json:"site"`
+}
+
+func toExported(entry entities.SeatLogEntry) exportedEntry {
+	return exportedEntry{
+		LoggedAt:   entry.LoggedAt,
+		CinemaName: entry.CinemaName,
+		FilmName:   entry.FilmName,
+		SessionId:  entry.SessionId,
+		StartHour:  entry.StartHour,
+		Seats:      entry.Seats,
+		Site:       entry.Site,
+	}
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvWriter) Write(entry entities.SeatLogEntry) error {
+	return c.w.Write([]string{
+		entry.LoggedAt.Format(time.RFC3339),
+		entry.CinemaName,
+		entry.FilmName,
+		entry.SessionId,
+		entry.StartHour,
+		strconv.Itoa(entry.Seats),
+		entry.Site,
+	})
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func (n *ndjsonWriter) Write(entry entities.SeatLogEntry) error {
+	return n.enc.Encode(toExported(entry))
+}
+
+func (n *ndjsonWriter) Close() error {
+	return nil
+}
+
+// jsonWriter writes a JSON array element by element
+type jsonWriter struct {
+	out   io.Writer
+	count int
+}
+
+func (j *jsonWriter) Write(entry entities.SeatLogEntry) error {
+	data, err := json.Marshal(toExported(entry))
+	if err != nil {
+		return err
+	}
+	sep := ",\n  "
+	if j.count == 0 {
+		sep = "[\n  "
+	}
+	j.count++
+	if _, err := io.WriteString(j.out, sep); err != nil {
+		return err
+	}
+	_, err = j.out.Write(data)
+	return err
+}
+
+func (j *jsonWriter) Close() error {
+	end := "\n]\n"
+	if j.count == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(j.out, end)
+	return err
+}
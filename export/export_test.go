@@ -0,0 +1,104 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/paologalligit/go-extractor/entities"
+	"github.com/paologalligit/go-extractor/persistence"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newLog(t *testing.T) *persistence.FilePersistence {
+	t.Helper()
+	p := persistence.NewFilePersistence(filepath.Join(t.TempDir(), "seat_counts.log"))
+	entries := []entities.SeatLogEntry{
+		{CinemaName: "Torino", FilmName: "A, the film", SessionId: "1", Seats: 10, StartHour: "19:50", LoggedAt: time.Date(2025, 9, 18, 20, 0, 0, 0, time.UTC)},
+		{Site: "vue-uk", CinemaName: "Vimercate", FilmName: "B", SessionId: "2", Seats: 5, StartHour: "21:00", LoggedAt: time.Date(2025, 9, 19, 21, 10, 0, 0, time.UTC)},
+	}
+	for _, entry := range entries {
+		require.NoError(t, p.WriteSessionSeats(context.Background(), entry))
+	}
+	return p
+}
+
+func TestRunExport(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		filter   persistence.SessionFilter
+		count    int
+		expected string
+	}{
+		{
+			name:   "csv",
+			format: FormatCSV,
+			count:  2,
+			expected: "logged_at,cinema_name,film_name,session_id,start_hour,seats,site\n" +
+				"2025-09-18T20:00:00Z,Torino,\"A, the film\",1,19:50,10,thespacecinema\n" +
+				"2025-09-19T21:10:00Z,Vimercate,B,2,21:00,5,vue-uk\n",
+		},
+		{
+			name:     "ndjson filtered by cinema",
+			format:   FormatNDJSON,
+			filter:   persistence.SessionFilter{CinemaName: "vimercate"},
+			count:    1,
+			expected: `{"logged_at":"2025-09-19T21:10:00Z","cinema_name":"Vimercate","film_name":"B","session_id":"2","start_hour":"21:00","seats":5,"site":"vue-uk"}` + "\n",
+		},
+		{
+			name:   "json filtered by date range",
+			format: FormatJSON,
+			filter: persistence.SessionFilter{
+				From: time.Date(2025, 9, 18, 0, 0, 0, 0, time.UTC),
+				To:   time.Date(2025, 9, 19, 0, 0, 0, 0, time.UTC),
+			},
+			count: 1,
+			expected: "[\n  " +
+				`{"logged_at":"2025-09-18T20:00:00Z","cinema_name":"Torino","film_name":"A, the film","session_id":"1","start_hour":"19:50","seats":10,"site":"thespacecinema"}` +
+				"\n]\n",
+		},
+		{
+			name:     "ndjson filtered by site, older entries belong to the default site",
+			format:   FormatNDJSON,
+			filter:   persistence.SessionFilter{Site: "thespacecinema"},
+			count:    1,
+			expected: `{"logged_at":"2025-09-18T20:00:00Z","cinema_name":"Torino","film_name":"A, the film","session_id":"1","start_hour":"19:50","seats":10,"site":"thespacecinema"}` + "\n",
+		},
+		{
+			name:     "json without matches",
+			format:   FormatJSON,
+			filter:   persistence.SessionFilter{SessionId: "404"},
+			count:    0,
+			expected: "[]\n",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			count, err := RunExport(context.Background(), &ExportOptions{
+				Reader: newLog(t),
+				Filter: tc.filter,
+				Format: tc.format,
+				Output: &out,
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tc.count, count)
+			assert.Equal(t, tc.expected, out.String())
+		})
+	}
+}
+
+func TestRunExport_MissingLog(t *testing.T) {
+	_, err := RunExport(context.Background(), &ExportOptions{
+		Reader: persistence.NewFilePersistence(filepath.Join(t.TempDir(), "missing.log")),
+		Format: FormatCSV,
+		Output: &bytes.Buffer{},
+	})
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
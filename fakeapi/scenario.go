@@ -0,0 +1,193 @@
+package fakeapi
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+	// Scenarios run in their own time zone, whatever the zoneinfo of the host
+	_ "time/tzdata"
+
+	"github.com/paologalligit/go-extractor/config"
+	"gopkg.in/yaml.v3"
+)
+
+// Endpoints served by the fake API, as named by Fault.Endpoint
+const (
+	EndpointCinemas        = "cinemas"
+	EndpointFilms          = "films"
+	EndpointShowings       = "showings"
+	EndpointShowingsByDate = "showings_by_date"
+	EndpointSeats          = "seats"
+)
+
+// Fault kinds
+const (
+	// FaultRateLimit answers 429 with Fault.RetryAfter
+	FaultRateLimit = "rate_limit"
+	// FaultSlow waits Fault.Delay before answering
+	FaultSlow = "slow"
+	// FaultExpiredCookies answers 401, as when the session cookies have expired
+	FaultExpiredCookies = "expired_cookies"
+	// FaultMalformedJSON answers 200 with a truncated JSON body
+	FaultMalformedJSON = "malformed_json"
+)
+
+var (
+	endpoints  = []string{EndpointCinemas, EndpointFilms, EndpointShowings, EndpointShowingsByDate, EndpointSeats}
+	faultKinds = []string{FaultRateLimit, FaultSlow, FaultExpiredCookies, FaultMalformedJSON}
+)
+
+// Scenario is the data served by the fake API and the faults it injects
+type Scenario struct {
+	// Timezone of the session start times, Europe/Rome by default
+	Timezone string `yaml:"timezone"`
+	// Start is the simulated time when the server starts, the wall clock by default
+	Start time.Time `yaml:"start"`
+	// Speed is the number of simulated seconds per real second, 1 by default
+	Speed    float64   `yaml:"speed"`
+	Cinemas  []Cinema  `yaml:"cinemas"`
+	Films    []Film    `yaml:"films"`
+	Sessions []Session `yaml:"sessions"`
+	Faults   []Fault   `yaml:"faults"`
+}
+
+type Cinema struct {
+	Id   string `yaml:"id"`
+	Name string `yaml:"name"`
+	// Region groups the cinemas in the cinemas endpoint
+	Region string `yaml:"region"`
+}
+
+type Film struct {
+	Id    string `yaml:"id"`
+	Title string `yaml:"title"`
+}
+
+// Session is one showing of a film at a cinema
+type Session struct {
+	Id     string `yaml:"id"`
+	Cinema string `yaml:"cinema"`
+	Film   string `yaml:"film"`
+	// Day is the showing date, in days after the date of Start
+	Day int `yaml:"day"`
+	// Time is the HH:MM start time on the showing date
+	Time string `yaml:"time"`
+	// Seats is the size of the screen, 100 by default
+	Seats     int       `yaml:"seats"`
+	Occupancy Occupancy `yaml:"occupancy"`
+}
+
+// Occupancy is the share of sold seats of a session over simulated time: Open until Ramp before
+// the start time, then growing linearly to reach Final when the session starts
+type Occupancy struct {
+	Open  float64         `yaml:"open"`
+	Final float64         `yaml:"final"`
+	Ramp  config.Duration `yaml:"ramp"`
+}
+
+// Fault alters the answers of the matching requests, counted per fault
+type Fault struct {
+	Kind string `yaml:"kind"`
+	// Endpoint restricts the fault to one endpoint, every endpoint when empty
+	Endpoint string `yaml:"endpoint"`
+	// After lets the first After matching requests through
+	After int `yaml:"after"`
+	// Times is the number of requests hit, every following request when 0
+	Times      int             `yaml:"times"`
+	Delay      config.Duration `yaml:"delay"`
+	RetryAfter config.Duration `yaml:"retry_after"`
+}
+
+// hits reports whether the n-th matching request, counted from 1, is hit by the fault
+func (f Fault) hits(n int) bool {
+	return n > f.After && (f.Times == 0 || n <= f.After+f.Times)
+}
+
+// LoadScenario reads a YAML scenario file
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scenario %s: %w", path, err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	var s Scenario
+	if err := dec.Decode(&s); err != nil {
+		return nil, fmt.Errorf("failed to parse scenario %s: %w", path, err)
+	}
+	if err := s.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid scenario %s: %w", path, err)
+	}
+	return &s, nil
+}
+
+// Validate reports every inconsistency of the scenario
+func (s *Scenario) Validate() error {
+	var errs []error
+	if _, err := s.location(); err != nil {
+		errs = append(errs, fmt.Errorf("timezone must be an IANA time zone like Europe/Rome, got %q", s.Timezone))
+	}
+	if s.Speed < 0 {
+		errs = append(errs, errors.New("speed must not be negative"))
+	}
+	cinemas := map[string]bool{}
+	for i, c := range s.Cinemas {
+		if c.Id == "" || cinemas[c.Id] {
+			errs = append(errs, fmt.Errorf("cinemas[%d].id %q is empty or used by another cinema", i, c.Id))
+		}
+		cinemas[c.Id] = true
+	}
+	films := map[string]bool{}
+	for i, f := range s.Films {
+		if f.Id == "" || films[f.Id] {
+			errs = append(errs, fmt.Errorf("films[%d].id %q is empty or used by another film", i, f.Id))
+		}
+		films[f.Id] = true
+	}
+	sessions := map[string]bool{}
+	for i, session := range s.Sessions {
+		if session.Id == "" || sessions[session.Id] {
+			errs = append(errs, fmt.Errorf("sessions[%d].id %q is empty or used by another session", i, session.Id))
+		}
+		sessions[session.Id] = true
+		if !cinemas[session.Cinema] {
+			errs = append(errs, fmt.Errorf("sessions[%d].cinema %q is not a cinema of the scenario", i, session.Cinema))
+		}
+		if !films[session.Film] {
+			errs = append(errs, fmt.Errorf("sessions[%d].film %q is not a film of the scenario", i, session.Film))
+		}
+		if _, err := time.Parse("15:04", session.Time); err != nil {
+			errs = append(errs, fmt.Errorf("sessions[%d].time must be a HH:MM time, got %q", i, session.Time))
+		}
+		if session.Seats < 0 {
+			errs = append(errs, fmt.Errorf("sessions[%d].seats must not be negative", i))
+		}
+		for _, v := range []float64{session.Occupancy.Open, session.Occupancy.Final} {
+			if v < 0 || v > 1 {
+				errs = append(errs, fmt.Errorf("sessions[%d].occupancy must be between 0 and 1, got %g", i, v))
+			}
+		}
+	}
+	for i, f := range s.Faults {
+		if !slices.Contains(faultKinds, f.Kind) {
+			errs = append(errs, fmt.Errorf("faults[%d].kind must be one of %v, got %q", i, faultKinds, f.Kind))
+		}
+		if f.Endpoint != "" && !slices.Contains(endpoints, f.Endpoint) {
+			errs = append(errs, fmt.Errorf("faults[%d].endpoint must be one of %v, got %q", i, endpoints, f.Endpoint))
+		}
+		if f.After < 0 || f.Times < 0 {
+			errs = append(errs, fmt.Errorf("faults[%d].after and times must not be negative", i))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Scenario) location() (*time.Location, error) {
+	if s.Timezone == "" {
+		return time.LoadLocation("Europe/Rome")
+	}
+	return time.LoadLocation(s.Timezone)
+}
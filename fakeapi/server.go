@@ -0,0 +1,311 @@
+package fakeapi
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/paologalligit/go-extractor/entities"
+)
+
+// seatsPerRow lays out the seat map of the seats endpoint
+const seatsPerRow = 10
+
+// Server is an http.Handler serving the cinemas, films, showings and seats endpoints of the API
+// from a Scenario, with the JSON shapes decoded by the entities package
+type Server struct {
+	scenario *Scenario
+	loc      *time.Location
+	// start is the simulated time at started, on the wall clock
+	start   time.Time
+	started time.Time
+	speed   float64
+	// clock is the wall clock, replaced in tests
+	clock func() time.Time
+	mux   *http.ServeMux
+
+	mu     sync.Mutex
+	counts []int // matching requests of each fault
+}
+
+// New returns a server playing scenario, its simulated clock starting now
+func New(scenario *Scenario) (*Server, error) {
+	return newServer(scenario, time.Now)
+}
+
+func newServer(scenario *Scenario, clock func() time.Time) (*Server, error) {
+	if err := scenario.Validate(); err != nil {
+		return nil, err
+	}
+	loc, _ := scenario.location()
+	s := &Server{
+		scenario: scenario,
+		loc:      loc,
+		started:  clock(),
+		start:    scenario.Start,
+		speed:    scenario.Speed,
+		clock:    clock,
+		mux:      http.NewServeMux(),
+		counts:   make([]int, len(scenario.Faults)),
+	}
+	if s.start.IsZero() {
+		s.start = s.started
+	}
+	if s.speed == 0 {
+		s.speed = 1
+	}
+	s.mux.HandleFunc("GET /api/microservice/showings/cinemas", s.route(EndpointCinemas, s.cinemas))
+	s.mux.HandleFunc("GET /api/microservice/showings/films", s.route(EndpointFilms, s.films))
+	s.mux.HandleFunc("GET /api/microservice/showings/cinemas/{cinemaId}/films", s.showings)
+	s.mux.HandleFunc("GET /api/microservice/booking/Session/{cinemaId}/{sessionId}/seats", s.route(EndpointSeats, s.seats))
+	return s, nil
+}
+
+// Now returns the simulated time
+func (s *Server) Now() time.Time {
+	elapsed := s.clock().Sub(s.started)
+	return s.start.Add(time.Duration(float64(elapsed) * s.speed)).In(s.loc)
+}
+
+// StartTime returns when session starts, in the time zone of the scenario
+func (s *Server) StartTime(session Session) time.Time {
+	clock, _ := time.Parse("15:04", session.Time)
+	day := s.start.In(s.loc)
+	return time.Date(day.Year(), day.Month(), day.Day()+session.Day, clock.Hour(), clock.Minute(), 0, 0, s.loc)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// route applies the faults of endpoint before handing the request to h
+func (s *Server) route(endpoint string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		for _, fault := range s.faultsFor(endpoint) {
+			fmt.Printf("💥 Injecting %s into %s\n", fault.Kind, r.URL)
+			switch fault.Kind {
+			case FaultSlow:
+				timer := time.NewTimer(fault.Delay.Duration())
+				select {
+				case <-timer.C:
+				case <-r.Context().Done():
+					timer.Stop()
+					return
+				}
+			case FaultRateLimit:
+				if retryAfter := fault.RetryAfter.Duration(); retryAfter > 0 {
+					w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+				}
+				writeError(w, http.StatusTooManyRequests, "too many requests")
+				return
+			case FaultExpiredCookies:
+				writeError(w, http.StatusUnauthorized, "token expired")
+				return
+			case FaultMalformedJSON:
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"result": [{"filmId": `))
+				return
+			}
+		}
+		h(w, r)
+	}
+}
+
+// faultsFor counts the request against the faults of endpoint and returns the ones hitting it,
+// the slow ones first so that they delay the others
+func (s *Server) faultsFor(endpoint string) []Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var hit []Fault
+	for i, fault := range s.scenario.Faults {
+		if fault.Endpoint != "" && fault.Endpoint != endpoint {
+			continue
+		}
+		s.counts[i]++
+		if fault.hits(s.counts[i]) {
+			hit = append(hit, fault)
+		}
+	}
+	sort.SliceStable(hit, func(i, j int) bool {
+		return hit[i].Kind == FaultSlow && hit[j].Kind != FaultSlow
+	})
+	return hit
+}
+
+func (s *Server) cinemas(w http.ResponseWriter, r *http.Request) {
+	var file entities.CinemasFile
+	regions := map[string]int{}
+	for _, c := range s.scenario.Cinemas {
+		i, ok := regions[c.Region]
+		if !ok {
+			i = len(file.Result)
+			regions[c.Region] = i
+			file.Result = append(file.Result, entities.Region{})
+		}
+		file.Result[i].Cinemas = append(file.Result[i].Cinemas, entities.Cinema{CinemaId: c.Id, CinemaName: c.Name})
+	}
+	writeCatalog(w, r, file)
+}
+
+// film is a film of the films endpoint and of the showings endpoints
+type film struct {
+	FilmId        string                  `json:"filmId"`
+	FilmTitle     string                  `json:"filmTitle"`
+	ShowingGroups []entities.ShowingGroup `json:"showingGroups,omitempty"`
+}
+
+func (s *Server) films(w http.ResponseWriter, r *http.Request) {
+	result := []film{}
+	for _, f := range s.scenario.Films {
+		result = append(result, film{FilmId: f.Id, FilmTitle: f.Title})
+	}
+	writeCatalog(w, r, map[string][]film{"result": result})
+}
+
+// showings serves the showings of a film at a cinema, or of every film at a cinema on a showing date
+func (s *Server) showings(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Has("showingDate") {
+		s.route(EndpointShowingsByDate, s.showingsByDate)(w, r)
+		return
+	}
+	s.route(EndpointShowings, s.filmShowings)(w, r)
+}
+
+func (s *Server) filmShowings(w http.ResponseWriter, r *http.Request) {
+	cinemaID, filmID := r.PathValue("cinemaId"), r.URL.Query().Get("filmId")
+	result := s.showingsOf(cinemaID, func(session Session) bool { return session.Film == filmID })
+	if len(result) == 0 {
+		writeError(w, http.StatusNotFound, "film not scheduled at this cinema")
+		return
+	}
+	writeJSON(w, map[string][]film{"result": result})
+}
+
+func (s *Server) showingsByDate(w http.ResponseWriter, r *http.Request) {
+	cinemaID, showingDate := r.PathValue("cinemaId"), r.URL.Query().Get("showingDate")
+	date, err := time.Parse("2006-01-02T15:04:05", showingDate)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid showingDate")
+		return
+	}
+	result := s.showingsOf(cinemaID, func(session Session) bool {
+		return s.StartTime(session).Format("2006-01-02") == date.Format("2006-01-02")
+	})
+	if len(result) == 0 {
+		writeError(w, http.StatusNotFound, "no showings at this cinema")
+		return
+	}
+	writeJSON(w, map[string][]film{"result": result})
+}
+
+// showingsOf groups the sessions at cinemaID selected by keep by film, then by showing date
+func (s *Server) showingsOf(cinemaID string, keep func(Session) bool) []film {
+	var sessions []Session
+	for _, session := range s.scenario.Sessions {
+		if session.Cinema == cinemaID && keep(session) {
+			sessions = append(sessions, session)
+		}
+	}
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return s.StartTime(sessions[i]).Before(s.StartTime(sessions[j]))
+	})
+
+	var result []film
+	for _, f := range s.scenario.Films {
+		showing := film{FilmId: f.Id, FilmTitle: f.Title}
+		for _, session := range sessions {
+			if session.Film != f.Id {
+				continue
+			}
+			start := s.StartTime(session)
+			date := start.Format("2006-01-02") + "T00:00:00"
+			if n := len(showing.ShowingGroups); n == 0 || showing.ShowingGroups[n-1].Date != date {
+				showing.ShowingGroups = append(showing.ShowingGroups, entities.ShowingGroup{Date: date})
+			}
+			group := &showing.ShowingGroups[len(showing.ShowingGroups)-1]
+			group.Sessions = append(group.Sessions, entities.Session{
+				SessionId: session.Id,
+				StartTime: start.Format("2006-01-02T15:04:05"),
+			})
+		}
+		if len(showing.ShowingGroups) > 0 {
+			result = append(result, showing)
+		}
+	}
+	return result
+}
+
+func (s *Server) seats(w http.ResponseWriter, r *http.Request) {
+	cinemaID, sessionID := r.PathValue("cinemaId"), r.PathValue("sessionId")
+	for _, session := range s.scenario.Sessions {
+		if session.Cinema != cinemaID || session.Id != sessionID {
+			continue
+		}
+		total := session.Seats
+		if total == 0 {
+			total = 100
+		}
+		var resp entities.Response
+		for placed := 0; placed < total; placed += seatsPerRow {
+			row := entities.SeatRow{}
+			for i := placed; i < min(placed+seatsPerRow, total); i++ {
+				row.Columns = append(row.Columns, &entities.Seat{})
+			}
+			resp.Result.SeatRows = append(resp.Result.SeatRows, row)
+		}
+		resp.Result.SessionOccupancy = session.Occupancy.at(s.StartTime(session), s.Now())
+		writeJSON(w, resp)
+		return
+	}
+	writeError(w, http.StatusNotFound, "session not found")
+}
+
+// at returns the occupancy at now of a session starting at start
+func (o Occupancy) at(start, now time.Time) float64 {
+	ramp := o.Ramp.Duration()
+	opens := start.Add(-ramp)
+	switch {
+	case !now.Before(start):
+		return o.Final
+	case now.Before(opens):
+		return o.Open
+	default:
+		return o.Open + (o.Final-o.Open)*float64(now.Sub(opens))/float64(ramp)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		fmt.Printf("❌ Failed to write response: %v\n", err)
+	}
+}
+
+// writeCatalog writes v with an ETag, answering 304 when the client already has it
+func writeCatalog(w http.ResponseWriter, r *http.Request, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	etag := fmt.Sprintf(`"%x"`, sha256.Sum256(body))
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
@@ -0,0 +1,222 @@
+package fakeapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/paologalligit/go-extractor/api"
+	"github.com/paologalligit/go-extractor/client"
+	"github.com/paologalligit/go-extractor/config"
+	"github.com/paologalligit/go-extractor/entities"
+	"github.com/paologalligit/go-extractor/header"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadScenario(t *testing.T) {
+	scenario, err := LoadScenario(filepath.Join("testdata", "scenario.yaml"))
+	require.NoError(t, err)
+	assert.Len(t, scenario.Cinemas, 2)
+	assert.Len(t, scenario.Sessions, 4)
+	assert.Equal(t, config.Duration(time.Hour), scenario.Sessions[0].Occupancy.Ramp)
+
+	path := filepath.Join(t.TempDir(), "broken.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+timezone: Mars/Olympus
+cinemas: [{id: "1"}]
+films: [{id: F}]
+sessions: [{id: "1", cinema: "2", film: F, time: 9pm, occupancy: {final: 2}}]
+faults: [{kind: meteor}]
+`), 0644))
+	_, err = LoadScenario(path)
+	assert.ErrorContains(t, err, "timezone must be an IANA time zone")
+	assert.ErrorContains(t, err, `sessions[0].cinema "2" is not a cinema of the scenario`)
+	assert.ErrorContains(t, err, "sessions[0].time must be a HH:MM time")
+	assert.ErrorContains(t, err, "sessions[0].occupancy must be between 0 and 1")
+	assert.ErrorContains(t, err, `faults[0].kind must be one of`)
+}
+
+// testClock is a wall clock moved by hand
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func newTestServer(t *testing.T, scenario *Scenario, clock *testClock) (*Server, *api.Endpoints) {
+	t.Helper()
+	server, err := newServer(scenario, clock.Now)
+	require.NoError(t, err)
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+	return server, api.New(httpServer.URL)
+}
+
+func testScenario() *Scenario {
+	return &Scenario{
+		Start:   time.Date(2025, 9, 15, 19, 0, 0, 0, time.UTC),
+		Speed:   60,
+		Cinemas: []Cinema{{Id: "1030", Name: "Vimercate", Region: "Lombardia"}, {Id: "1018", Name: "Torino", Region: "Piemonte"}},
+		Films:   []Film{{Id: "F1", Title: "A"}, {Id: "F2", Title: "B"}},
+		Sessions: []Session{
+			{Id: "1", Cinema: "1030", Film: "F1", Day: 0, Time: "22:00", Seats: 15, Occupancy: Occupancy{Open: 0.2, Final: 0.8, Ramp: config.Duration(time.Hour)}},
+			{Id: "2", Cinema: "1030", Film: "F1", Day: 1, Time: "18:00"},
+			{Id: "3", Cinema: "1030", Film: "F2", Day: 0, Time: "21:00"},
+		},
+	}
+}
+
+func TestServer_Endpoints(t *testing.T) {
+	clock := &testClock{now: time.Now()}
+	server, endpoints := newTestServer(t, testScenario(), clock)
+	c := client.New(header.NoAuthProvider{})
+	ctx := context.Background()
+
+	body, err := c.Get(ctx, endpoints.Cinemas())
+	require.NoError(t, err)
+	var cinemas entities.CinemasFile
+	require.NoError(t, json.Unmarshal(body, &cinemas))
+	assert.Equal(t, []entities.Region{
+		{Cinemas: []entities.Cinema{{CinemaId: "1030", CinemaName: "Vimercate"}}},
+		{Cinemas: []entities.Cinema{{CinemaId: "1018", CinemaName: "Torino"}}},
+	}, cinemas.Result)
+
+	body, err = c.Get(ctx, endpoints.Films())
+	require.NoError(t, err)
+	var films entities.FilmsFile
+	require.NoError(t, json.Unmarshal(body, &films))
+	assert.Equal(t, []entities.Film{{FilmId: "F1"}, {FilmId: "F2"}}, films.Result)
+
+	// The catalogs carry an ETag, sending it back answers 304
+	catalog, err := c.GetIfModified(ctx, endpoints.Films(), client.Validators{})
+	require.NoError(t, err)
+	require.NotEmpty(t, catalog.Validators.ETag)
+	catalog, err = c.GetIfModified(ctx, endpoints.Films(), catalog.Validators)
+	require.NoError(t, err)
+	assert.True(t, catalog.NotModified)
+
+	// Start times are in Europe/Rome by default: the 19:00 UTC start is 21:00 there
+	showings, err := c.CallShowings(ctx, endpoints.Showings("1030", "F1"))
+	require.NoError(t, err)
+	require.Len(t, showings.Result, 1)
+	assert.Equal(t, "A", showings.Result[0].FilmTitle)
+	assert.Equal(t, []entities.ShowingGroup{
+		{Date: "2025-09-15T00:00:00", Sessions: []entities.Session{{SessionId: "1", StartTime: "2025-09-15T22:00:00"}}},
+		{Date: "2025-09-16T00:00:00", Sessions: []entities.Session{{SessionId: "2", StartTime: "2025-09-16T18:00:00"}}},
+	}, showings.Result[0].ShowingGroups)
+
+	_, err = c.CallShowings(ctx, endpoints.Showings("1018", "F1"))
+	assert.ErrorIs(t, err, client.ErrNotFound)
+
+	showings, err = c.CallShowings(ctx, endpoints.ShowingsForDate("1030", time.Date(2025, 9, 15, 0, 0, 0, 0, time.UTC)))
+	require.NoError(t, err)
+	require.Len(t, showings.Result, 2)
+	assert.Equal(t, "F1", showings.Result[0].FilmId)
+	assert.Len(t, showings.Result[0].ShowingGroups, 1)
+	assert.Equal(t, "F2", showings.Result[1].FilmId)
+
+	// Session 1 starts at 22:00, its occupancy ramps from 21:00; the clock runs 60 times faster
+	for _, tc := range []struct {
+		simulated string
+		occupancy float64
+	}{
+		{simulated: "21:00", occupancy: 0.2},
+		{simulated: "21:30", occupancy: 0.5},
+		{simulated: "23:00", occupancy: 0.8},
+	} {
+		at, err := time.ParseInLocation("2006-01-02 15:04", "2025-09-15 "+tc.simulated, server.loc)
+		require.NoError(t, err)
+		clock.now = server.started.Add(at.Sub(server.start) / 60)
+		assert.Equal(t, at, server.Now())
+
+		seats, err := c.CallSeats(ctx, endpoints.Seats("1030", "1"))
+		require.NoError(t, err)
+		assert.Equal(t, 15, seats.Result.SeatRows.CountSeats())
+		assert.Len(t, seats.Result.SeatRows, 2)
+		assert.InDelta(t, tc.occupancy, seats.Result.SessionOccupancy, 1e-9, "at %s", tc.simulated)
+	}
+
+	_, err = c.CallSeats(ctx, endpoints.Seats("1018", "1"))
+	assert.ErrorIs(t, err, client.ErrNotFound)
+}
+
+func TestServer_Faults(t *testing.T) {
+	tests := []struct {
+		name    string
+		fault   Fault
+		timeout time.Duration
+		check   func(t *testing.T, err error)
+	}{
+		{
+			name:  "rate limit",
+			fault: Fault{Kind: FaultRateLimit, Endpoint: EndpointSeats, RetryAfter: config.Duration(2 * time.Second)},
+			check: func(t *testing.T, err error) {
+				var httpErr *client.HTTPError
+				require.ErrorAs(t, err, &httpErr)
+				assert.ErrorIs(t, err, client.ErrRateLimited)
+				assert.Equal(t, 2*time.Second, httpErr.RetryAfter)
+			},
+		},
+		{
+			name:  "expired cookies",
+			fault: Fault{Kind: FaultExpiredCookies},
+			check: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, client.ErrUnauthorized)
+			},
+		},
+		{
+			name:  "malformed json",
+			fault: Fault{Kind: FaultMalformedJSON, Endpoint: EndpointSeats},
+			check: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, client.ErrDecode)
+			},
+		},
+		{
+			name:    "slow response",
+			fault:   Fault{Kind: FaultSlow, Delay: config.Duration(time.Minute)},
+			timeout: 50 * time.Millisecond,
+			check: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, context.DeadlineExceeded)
+			},
+		},
+		{
+			name:  "fault on another endpoint",
+			fault: Fault{Kind: FaultExpiredCookies, Endpoint: EndpointShowings},
+			check: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			scenario := testScenario()
+			// Only the second request is hit
+			tc.fault.After, tc.fault.Times = 1, 1
+			scenario.Faults = []Fault{tc.fault}
+			_, endpoints := newTestServer(t, scenario, &testClock{now: time.Now()})
+			c := client.NewWithOptions(header.NoAuthProvider{}, client.Options{})
+
+			call := func() error {
+				ctx := context.Background()
+				if tc.timeout > 0 {
+					var cancel context.CancelFunc
+					ctx, cancel = context.WithTimeout(ctx, tc.timeout)
+					defer cancel()
+				}
+				_, err := c.CallSeats(ctx, endpoints.Seats("1030", "1"))
+				return err
+			}
+			require.NoError(t, call())
+			tc.check(t, call())
+			require.NoError(t, call())
+		})
+	}
+}
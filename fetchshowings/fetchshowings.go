@@ -1,40 +1,50 @@
 package fetchshowings
 
 import (
+	"context"
 	"fmt"
+	"time"
 
+	"github.com/paologalligit/go-extractor/catalog"
 	"github.com/paologalligit/go-extractor/client"
 	"github.com/paologalligit/go-extractor/entities"
-	"github.com/paologalligit/go-extractor/header"
+	"github.com/paologalligit/go-extractor/site"
 	"github.com/paologalligit/go-extractor/team"
 	"github.com/paologalligit/go-extractor/utils"
 )
 
 type FetchShowingsOptions struct {
-	MaxGoroutines  int
-	RequestDelay   int
-	ShowingUrl     string
+	MaxGoroutines int
+	Site          site.Site
+	// FilesPath holds the cinemas and films of Site
+	FilesPath      string
 	OutputFileName string
-	CookiesManager *header.CookiesManager
+	Client         *client.ExtractorClient
+	// CatalogTTL is how long the stored cinemas and films are used before asking the API again
+	CatalogTTL time.Duration
 }
 
-// RunFetchShowings fetches showings and writes them to a file
-func RunFetchShowings(options *FetchShowingsOptions) error {
-	if err := utils.FetchCinemas(options.CookiesManager); err != nil {
+// RunFetchShowings fetches the showings of options.Site and writes them to a file
+func RunFetchShowings(ctx context.Context, options *FetchShowingsOptions) error {
+	endpoints := options.Site.Endpoints()
+	cache := catalog.New(options.FilesPath, options.CatalogTTL, options.Client, endpoints)
+	status, err := cache.Ensure(ctx, catalog.Cinemas)
+	if err != nil {
 		return fmt.Errorf("failed to fetch cinemas: %w", err)
 	}
-	fmt.Println("🏠 Cinemas fetched")
-	if err := utils.FetchFilms(options.CookiesManager); err != nil {
+	fmt.Printf("🏠 Cinemas %s\n", status)
+	status, err = cache.Ensure(ctx, catalog.Films)
+	if err != nil {
 		return fmt.Errorf("failed to fetch films: %w", err)
 	}
-	fmt.Println("🎬 Films fetched")
+	fmt.Printf("🎬 Films %s\n", status)
 
 	// Read cinema and film data
-	cinemaIds, regionData, err := utils.GetCinemaIds()
+	cinemaIds, regionData, err := utils.GetCinemaIds(options.FilesPath)
 	if err != nil {
 		return fmt.Errorf("failed to get cinema ids: %w", err)
 	}
-	filmIds, err := utils.GetFilmIds()
+	filmIds, err := utils.GetFilmIds(options.FilesPath)
 	if err != nil {
 		return fmt.Errorf("failed to get film ids: %w", err)
 	}
@@ -59,12 +69,16 @@ func RunFetchShowings(options *FetchShowingsOptions) error {
 	fmt.Printf("👷 Starting %d workers\n", workerCount)
 
 	fetchTeam := team.NewFetchTeam(workerCount, &team.FetchTeamWorkingMaterial{
-		Client:       client.New(options.CookiesManager),
-		ShowingUrl:   options.ShowingUrl,
-		RequestDelay: options.RequestDelay,
-		RegionData:   regionData,
+		Client:     options.Client,
+		Endpoints:  endpoints,
+		Site:       options.Site.Name,
+		RegionData: regionData,
 	})
-	finalResults := fetchTeam.Run(workItems)
+	finalResults := fetchTeam.Run(ctx, workItems)
+	close(stopProgress)
+	if err := ctx.Err(); err != nil {
+		fmt.Printf("\n🛑 Interrupted, writing the %d showings fetched so far\n", len(finalResults))
+	}
 
 	// Write results to file
 	if err := utils.WriteResultsToFile(finalResults, options.OutputFileName); err != nil {
@@ -0,0 +1,128 @@
+package header
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// cookieCache is the content of the cookie cache file
+type cookieCache struct {
+	// Cookies is the flattened header, read when Entries is missing from older caches
+	Cookies                   string         `json:"cookies"`
+	Entries                   []cachedCookie `json:"entries,omitempty"`
+	AccessTokenExpirationTime time.Time      `json:"accessTokenExpirationTime"`
+	SavedAt                   time.Time      `json:"savedAt"`
+}
+
+// cachedCookie keeps the attributes the jar needs to scope a cookie
+type cachedCookie struct {
+	Name     string    `json:"name"`
+	Value    string    `json:"value"`
+	Domain   string    `json:"domain,omitempty"`
+	Path     string    `json:"path,omitempty"`
+	Expires  time.Time `json:"expires,omitzero"`
+	Secure   bool      `json:"secure,omitempty"`
+	HttpOnly bool      `json:"httpOnly,omitempty"`
+}
+
+// loadCache returns the cached cookies, which may have expired since they were saved
+func (c *CookiesManager) loadCache() ([]*http.Cookie, bool) {
+	if c.cacheFile == "" {
+		return nil, false
+	}
+	data, err := os.ReadFile(c.cacheFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			fmt.Printf("Ignoring cookie cache: %v\n", err)
+		}
+		return nil, false
+	}
+	var cache cookieCache
+	if err := json.Unmarshal(data, &cache); err != nil {
+		fmt.Printf("Ignoring corrupted cookie cache %s: %v\n", c.cacheFile, err)
+		return nil, false
+	}
+	if len(cache.Entries) == 0 {
+		cookies := parseCookieHeader(cache.Cookies)
+		return cookies, len(cookies) > 0
+	}
+	cookies := make([]*http.Cookie, 0, len(cache.Entries))
+	for _, e := range cache.Entries {
+		cookies = append(cookies, &http.Cookie{
+			Name:     e.Name,
+			Value:    e.Value,
+			Domain:   e.Domain,
+			Path:     e.Path,
+			Expires:  e.Expires,
+			Secure:   e.Secure,
+			HttpOnly: e.HttpOnly,
+		})
+	}
+	return cookies, true
+}
+
+// saveCache writes cookies to the cache file, readable by the owner only.
+// Failures are logged: the cache is an optimisation, the cookies in memory are still valid.
+func (c *CookiesManager) saveCache(cookies []*http.Cookie) {
+	if c.cacheFile == "" {
+		return
+	}
+	if err := writeCache(c.cacheFile, cookies, c.timeProvider.Now()); err != nil {
+		fmt.Printf("❌ Failed to write cookie cache %s: %v\n", c.cacheFile, err)
+	}
+}
+
+func writeCache(path string, cookies []*http.Cookie, now time.Time) error {
+	header := flattenCookies(cookies)
+	expiration, err := accessTokenExpiration(header)
+	if err != nil {
+		return fmt.Errorf("cookies have no valid expiration: %w", err)
+	}
+	cache := cookieCache{
+		Cookies:                   header,
+		AccessTokenExpirationTime: expiration,
+		SavedAt:                   now,
+	}
+	for _, c := range cookies {
+		cache.Entries = append(cache.Entries, cachedCookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   c.Domain,
+			Path:     c.Path,
+			Expires:  c.Expires,
+			Secure:   c.Secure,
+			HttpOnly: c.HttpOnly,
+		})
+	}
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	// Write to a temp file and rename, so a crash never leaves a truncated cache
+	tmp, err := os.CreateTemp(dir, ".cookies-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
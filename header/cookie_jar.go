@@ -0,0 +1,126 @@
+package header
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JarProvider is implemented by the providers keeping structured cookies.
+// HTTP clients use the jar to send each cookie only where its domain and path allow,
+// and to store the Set-Cookie updates the API returns.
+type JarProvider interface {
+	Jar() http.CookieJar
+}
+
+// NewHTTPClient returns a client sending the provider's cookies through its jar, when it has one
+func NewHTTPClient(provider CredentialProvider) *http.Client {
+	client := &http.Client{}
+	if p, ok := provider.(JarProvider); ok {
+		client.Jar = p.Jar()
+	}
+	return client
+}
+
+// sessionJar is the cookie jar handed to HTTP clients. A refresh replaces the cookies it holds
+// without replacing the jar, and onSet reports the Set-Cookie updates back to the manager.
+type sessionJar struct {
+	origin *url.URL
+	onSet  func(cookies []*http.Cookie)
+
+	mu  sync.RWMutex
+	jar *cookiejar.Jar
+}
+
+func newSessionJar(origin *url.URL, onSet func(cookies []*http.Cookie)) *sessionJar {
+	j := &sessionJar{origin: origin, onSet: onSet}
+	j.reset(nil)
+	return j
+}
+
+// reset replaces the jar content with cookies, as set by origin
+func (j *sessionJar) reset(cookies []*http.Cookie) {
+	jar, _ := cookiejar.New(nil) // never fails without options
+	jar.SetCookies(j.origin, cookies)
+	j.mu.Lock()
+	j.jar = jar
+	j.mu.Unlock()
+}
+
+func (j *sessionJar) current() *cookiejar.Jar {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.jar
+}
+
+func (j *sessionJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.current().SetCookies(u, cookies)
+	if j.onSet != nil {
+		j.onSet(cookies)
+	}
+}
+
+func (j *sessionJar) Cookies(u *url.URL) []*http.Cookie {
+	return j.current().Cookies(u)
+}
+
+// flattenCookies formats cookies as a "name=value; name=value" header, whatever their domain
+func flattenCookies(cookies []*http.Cookie) string {
+	pairs := make([]string, 0, len(cookies))
+	for _, c := range cookies {
+		pairs = append(pairs, c.Name+"="+c.Value)
+	}
+	return strings.Join(pairs, "; ")
+}
+
+// parseCookieHeader reads a "name=value; name=value" header into host-only cookies
+func parseCookieHeader(header string) []*http.Cookie {
+	var cookies []*http.Cookie
+	for pair := range strings.SplitSeq(header, "; ") {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok || name == "" {
+			continue
+		}
+		cookies = append(cookies, &http.Cookie{Name: name, Value: value, Path: "/"})
+	}
+	return cookies
+}
+
+// mergeCookies applies Set-Cookie updates to cookies: values are replaced by name, new cookies
+// are appended and deleted or expired ones removed. cookies is left untouched.
+func mergeCookies(cookies, updates []*http.Cookie, now time.Time) []*http.Cookie {
+	merged := make([]*http.Cookie, len(cookies))
+	copy(merged, cookies)
+	for _, update := range updates {
+		deleted := update.MaxAge < 0 || (!update.Expires.IsZero() && !update.Expires.After(now))
+		i := indexOfCookie(merged, update.Name)
+		switch {
+		case i >= 0 && deleted:
+			merged = append(merged[:i], merged[i+1:]...)
+		case i >= 0:
+			replaced := *update
+			if replaced.Domain == "" {
+				replaced.Domain = merged[i].Domain
+			}
+			if replaced.Path == "" {
+				replaced.Path = merged[i].Path
+			}
+			merged[i] = &replaced
+		case !deleted:
+			merged = append(merged, update)
+		}
+	}
+	return merged
+}
+
+func indexOfCookie(cookies []*http.Cookie, name string) int {
+	for i, c := range cookies {
+		if c.Name == name {
+			return i
+		}
+	}
+	return -1
+}
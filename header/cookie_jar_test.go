@@ -0,0 +1,129 @@
+package header
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCookiesManager_Jar(t *testing.T) {
+	exp := time.Now().Add(time.Hour).UTC()
+	var received []*http.Cookie
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Cookies()
+		http.SetCookie(w, &http.Cookie{Name: "microservicesToken", Value: "rotated", Path: "/"})
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+	host, _ := url.Parse(server.URL)
+
+	browserCookies := []*http.Cookie{
+		{Name: "microservicesToken", Value: "initial", Path: "/"},
+		{Name: "accessTokenExpirationTime", Value: url.QueryEscape(exp.Format(time.RFC3339)), Path: "/"},
+		{Name: "booking", Value: "scoped", Path: "/booking"},
+		{Name: "analytics", Value: "tracker", Domain: "analytics.example.com", Path: "/"},
+	}
+	cm, err := newCookiesManager(CookiesManagerOptions{BaseURL: server.URL}, func(string) ([]*http.Cookie, error) {
+		return browserCookies, nil
+	})
+	require.NoError(t, err)
+	defer cm.Close()
+
+	client := NewHTTPClient(cm)
+	require.NotNil(t, client.Jar)
+	headers, err := GetHeaders(cm)
+	require.NoError(t, err)
+	assert.NotContains(t, headers, "cookie", "the jar sends the cookies")
+
+	resp, err := client.Get(server.URL + "/api/showings")
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	// Only the cookies scoped to the requested host and path are sent
+	names := map[string]string{}
+	for _, c := range received {
+		names[c.Name] = c.Value
+	}
+	assert.Equal(t, "initial", names["microservicesToken"])
+	assert.Contains(t, names, "accessTokenExpirationTime")
+	assert.NotContains(t, names, "booking")
+	assert.NotContains(t, names, "analytics")
+
+	// Set-Cookie updates reach the manager and the next requests
+	credentials, err := cm.Credentials()
+	require.NoError(t, err)
+	assert.Equal(t, "rotated", credentials.AccessToken)
+	sent := cm.Jar().Cookies(host)
+	assert.Contains(t, flattenCookies(sent), "microservicesToken=rotated")
+}
+
+func TestCookiesManager_InvalidateAfterSetCookie(t *testing.T) {
+	exp := url.QueryEscape(time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+	tests := []struct {
+		name string
+		// setCookie comes with the rejection
+		setCookie *http.Cookie
+	}{
+		{
+			name:      "unrelated cookie updated",
+			setCookie: &http.Cookie{Name: "__cflb", Value: "balanced", Path: "/"},
+		},
+		{
+			name:      "rejected token cleared",
+			setCookie: &http.Cookie{Name: "microservicesToken", Value: "", Path: "/", MaxAge: -1},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var sent []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				token, err := r.Cookie("microservicesToken")
+				if err != nil || token.Value != "renewed" {
+					sent = append(sent, "rejected")
+					http.SetCookie(w, tc.setCookie)
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				sent = append(sent, token.Value)
+				w.Write([]byte("{}"))
+			}))
+			defer server.Close()
+
+			fetched := []string{"revoked", "renewed"}
+			cm, err := newCookiesManager(CookiesManagerOptions{BaseURL: server.URL}, func(string) ([]*http.Cookie, error) {
+				token := fetched[0]
+				fetched = fetched[1:]
+				return []*http.Cookie{
+					{Name: "microservicesToken", Value: token, Path: "/"},
+					{Name: "accessTokenExpirationTime", Value: exp, Path: "/"},
+				}, nil
+			})
+			require.NoError(t, err)
+			defer cm.Close()
+			client := NewHTTPClient(cm)
+
+			get := func() (string, int) {
+				cookies, err := cm.GetCookies()
+				require.NoError(t, err)
+				resp, err := client.Get(server.URL + "/api/showings")
+				require.NoError(t, err)
+				resp.Body.Close()
+				return cookies, resp.StatusCode
+			}
+			rejected, status := get()
+			require.Equal(t, http.StatusUnauthorized, status)
+			require.NoError(t, cm.Invalidate(rejected))
+			_, status = get()
+
+			assert.Equal(t, http.StatusOK, status)
+			assert.Equal(t, []string{"rejected", "renewed"}, sent)
+			assert.Empty(t, fetched, "the rejected token is renewed")
+		})
+	}
+}
@@ -2,8 +2,9 @@ package header
 
 import (
 	"fmt"
+	"net/http"
 	"net/url"
-	"strings"
+	"sync"
 	"time"
 
 	"github.com/playwright-community/playwright-go"
@@ -19,53 +20,290 @@ func (realTimeProvider) Now() time.Time {
 	return time.Now()
 }
 
+// refreshRetryDelay is the wait before retrying a failed background refresh
+const refreshRetryDelay = time.Minute
+
+// CookiesManagerOptions configures a CookiesManager
+type CookiesManagerOptions struct {
+	// BaseURL is the site the browser visits to collect cookies, defaults to BASE_URL
+	BaseURL string
+	// CacheFile persists the cookies across restarts; empty disables the cache
+	CacheFile string
+	// RefreshBefore refreshes the cookies in the background this long before the access token expires;
+	// zero disables the background refresh
+	RefreshBefore time.Duration
+	// RefreshURL exchanges the microservicesRefreshToken cookie for a new access token without a browser;
+	// empty always refreshes through Playwright
+	RefreshURL string
+	// HTTPClient calls RefreshURL, defaults to a client with a short timeout
+	HTTPClient *http.Client
+	// TimeProvider defaults to the wall clock
+	TimeProvider TimeProvider
+}
+
+// refreshCall is a refresh in flight, whose result is shared by every caller waiting on it
+type refreshCall struct {
+	done chan struct{}
+	err  error
+}
+
+// CookiesManager is safe for concurrent use
 type CookiesManager struct {
-	cookies      string
+	mu sync.RWMutex
+	// entries are the browser cookies with their domain, path and expiry
+	entries []*http.Cookie
+	// cookies flattens entries into a header value
+	cookies string
+	// credential identifies the access token last issued, by a refresh or a Set-Cookie renewing it.
+	// Invalidate compares it with the rejected cookies: the other Set-Cookie updates leave it alone.
+	credential   string
+	jar          *sessionJar
+	baseURL      string
+	inflight     *refreshCall
 	timeProvider TimeProvider
+	cacheFile    string
+	refreshURL   string
+	httpClient   *http.Client
+	// fetchCookies retrieves brand new cookies, through Playwright outside of tests
+	fetchCookies func(baseURL string) ([]*http.Cookie, error)
+	stop         chan struct{}
+	stopOnce     sync.Once
 }
 
 const BASE_URL = "https://www.thespacecinema.it/"
 
 func New() (*CookiesManager, error) {
-	fmt.Println("Retrieving cookies for the first time...")
-	cookies, err := getCookiesFromBaseURL(BASE_URL)
+	return NewWithOptions(CookiesManagerOptions{})
+}
+
+func NewWithTimeProvider(tp TimeProvider) (*CookiesManager, error) {
+	return NewWithOptions(CookiesManagerOptions{TimeProvider: tp})
+}
+
+// NewWithOptions reuses the cached cookies while they are valid, else refreshes them.
+// When RefreshBefore is set, Close must be called to stop the background refresh.
+func NewWithOptions(opts CookiesManagerOptions) (*CookiesManager, error) {
+	return newCookiesManager(opts, getCookiesFromBaseURL)
+}
+
+func newCookiesManager(opts CookiesManagerOptions, fetch func(baseURL string) ([]*http.Cookie, error)) (*CookiesManager, error) {
+	if opts.BaseURL == "" {
+		opts.BaseURL = BASE_URL
+	}
+	origin, err := url.Parse(opts.BaseURL)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get cookies: %w", err)
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+	c := &CookiesManager{
+		baseURL:      opts.BaseURL,
+		timeProvider: opts.TimeProvider,
+		cacheFile:    opts.CacheFile,
+		refreshURL:   opts.RefreshURL,
+		httpClient:   opts.HTTPClient,
+		fetchCookies: fetch,
+		stop:         make(chan struct{}),
+	}
+	if c.timeProvider == nil {
+		c.timeProvider = realTimeProvider{}
+	}
+	if c.httpClient == nil {
+		c.httpClient = &http.Client{Timeout: refreshTimeout}
+	}
+	c.jar = newSessionJar(origin, c.mergeSetCookies)
+
+	// Expired cached cookies are kept: their refresh token may still avoid launching a browser
+	if cached, ok := c.loadCache(); ok {
+		c.setEntries(cached)
+		warnConflicts(c.cookies)
+	}
+	if c.cookies != "" && !c.isExpired() {
+		fmt.Println("Reusing cached cookies from", c.cacheFile)
+	} else {
+		fmt.Println("Retrieving cookies for the first time...")
+		if err := c.refresh(); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.RefreshBefore > 0 {
+		go c.refreshLoop(opts.RefreshBefore)
 	}
-	return &CookiesManager{
-		cookies:      cookies,
-		timeProvider: realTimeProvider{},
-	}, nil
+	return c, nil
 }
 
-func NewWithTimeProvider(tp TimeProvider) (*CookiesManager, error) {
-	fmt.Println("Retrieving cookies for the first time...")
-	cookies, err := getCookiesFromBaseURL(BASE_URL)
+func (c *CookiesManager) GetCookies() (string, error) {
+	c.mu.RLock()
+	if !c.isExpired() {
+		defer c.mu.RUnlock()
+		return c.cookies, nil
+	}
+	c.mu.RUnlock()
+
+	if err := c.singleFlight(c.isExpired); err != nil {
+		return "", err
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cookies, nil
+}
+
+// Close stops the background refresh
+func (c *CookiesManager) Close() error {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+	return nil
+}
+
+// Invalidate renews the cookies after the server rejected them before their expiration.
+// rejected are the cookies sent with the failed request: if their access token was already replaced,
+// no refresh is started. Other cookies may have changed since, e.g. set by the rejection itself.
+func (c *CookiesManager) Invalidate(rejected string) error {
+	return c.singleFlight(func() bool {
+		return c.credential == credentialKey(rejected)
+	})
+}
+
+// credentialKey identifies the credentials carried by cookies: the access token, else its expiration
+func credentialKey(cookies string) string {
+	if token, err := cookieValue(cookies, "microservicesToken"); err == nil && token != "" {
+		return token
+	}
+	expiration, _ := cookieValue(cookies, "accessTokenExpirationTime")
+	return expiration
+}
+
+// refresh renews the cookies even if they are still valid
+func (c *CookiesManager) refresh() error {
+	return c.singleFlight(nil)
+}
+
+// singleFlight renews the cookies, unless needed is set and reports that another caller already did;
+// needed is called with c.mu held. Concurrent callers wait on the refresh in flight and share its error,
+// so a token expiring under many workers launches a single browser. Readers are not blocked while it runs.
+func (c *CookiesManager) singleFlight(needed func() bool) error {
+	c.mu.Lock()
+	if call := c.inflight; call != nil {
+		c.mu.Unlock()
+		<-call.done
+		return call.err
+	}
+	if needed != nil {
+		if !needed() {
+			c.mu.Unlock()
+			return nil
+		}
+		fmt.Println("Cookies expired or rejected! Time to fetch them brand new...")
+	}
+	call := &refreshCall{done: make(chan struct{})}
+	c.inflight = call
+	current := c.entries
+	c.mu.Unlock()
+
+	entries, err := c.renew(current)
+	if err == nil {
+		warnConflicts(flattenCookies(entries))
+		c.saveCache(entries)
+	}
+
+	c.mu.Lock()
+	if err == nil {
+		c.setEntries(entries)
+	}
+	c.inflight = nil
+	c.mu.Unlock()
+
+	call.err = err
+	close(call.done)
+	return err
+}
+
+// renew returns new cookies for current ones. The refresh token is exchanged over HTTP
+// when possible, Playwright is the fallback.
+func (c *CookiesManager) renew(current []*http.Cookie) ([]*http.Cookie, error) {
+	if c.refreshURL != "" && len(current) > 0 {
+		cookies, err := exchangeRefreshToken(c.httpClient, c.refreshURL, current, c.timeProvider.Now())
+		if err == nil {
+			fmt.Println("Cookies refreshed with the refresh token")
+			return cookies, nil
+		}
+		fmt.Printf("Refresh token exchange failed, falling back to the browser: %v\n", err)
+	}
+
+	cookies, err := c.fetchCookies(c.baseURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get cookies: %w", err)
 	}
-	return &CookiesManager{
-		cookies:      cookies,
-		timeProvider: tp,
-	}, nil
+	return cookies, nil
 }
 
-func (c *CookiesManager) GetCookies() (string, error) {
-	if c.IsExpired() {
-		fmt.Println("Cookies expired! Time to fetch them brand new...")
-		cookies, err := getCookiesFromBaseURL(BASE_URL)
-		if err != nil {
-			return "", fmt.Errorf("failed to get cookies: %w", err)
+// setEntries replaces the cookies and the content of the jar; c.mu must be held
+func (c *CookiesManager) setEntries(entries []*http.Cookie) {
+	c.entries = entries
+	c.cookies = flattenCookies(entries)
+	c.credential = credentialKey(c.cookies)
+	if c.jar != nil {
+		c.jar.reset(entries)
+	}
+}
+
+// mergeSetCookies records the Set-Cookie updates received by the jar during the session.
+// The credential changes only when the server issues a new access token, not when it updates
+// or clears the other cookies, e.g. on the response rejecting the current one.
+func (c *CookiesManager) mergeSetCookies(updates []*http.Cookie) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = mergeCookies(c.entries, updates, c.timeProvider.Now())
+	c.cookies = flattenCookies(c.entries)
+	if token, err := cookieValue(c.cookies, "microservicesToken"); err == nil && token != "" && token != c.credential {
+		c.credential = token
+	}
+}
+
+// Jar returns the cookie jar to attach to the HTTP clients calling the API.
+// It stays the same across refreshes.
+func (c *CookiesManager) Jar() http.CookieJar {
+	return c.jar
+}
+
+// refreshLoop refreshes the cookies refreshBefore their expiration, until Close is called
+func (c *CookiesManager) refreshLoop(refreshBefore time.Duration) {
+	// After a refresh, wait at least refreshRetryDelay even if the new token is already close to expiring
+	minWait := time.Duration(0)
+	for {
+		wait := refreshRetryDelay
+		c.mu.RLock()
+		expiration, err := accessTokenExpiration(c.cookies)
+		c.mu.RUnlock()
+		if err == nil {
+			wait = expiration.Add(-refreshBefore).Sub(c.timeProvider.Now())
+		}
+
+		timer := time.NewTimer(max(wait, minWait))
+		select {
+		case <-c.stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		fmt.Println("Cookies about to expire, refreshing them in the background...")
+		minWait = refreshRetryDelay
+		if err := c.refresh(); err != nil {
+			fmt.Printf("❌ Background cookies refresh failed, retrying in %s: %v\n", refreshRetryDelay, err)
+			select {
+			case <-c.stop:
+				return
+			case <-time.After(refreshRetryDelay):
+			}
 		}
-		c.cookies = cookies
 	}
-	return c.cookies, nil
 }
 
-func getCookiesFromBaseURL(baseURL string) (string, error) {
+func getCookiesFromBaseURL(baseURL string) ([]*http.Cookie, error) {
 	pw, err := playwright.Run()
 	if err != nil {
-		return "", fmt.Errorf("could not launch playwright: %w", err)
+		return nil, fmt.Errorf("could not launch playwright: %w", err)
 	}
 	defer pw.Stop()
 
@@ -73,20 +311,20 @@ func getCookiesFromBaseURL(baseURL string) (string, error) {
 		Headless: playwright.Bool(true),
 	})
 	if err != nil {
-		return "", fmt.Errorf("could not launch browser: %w", err)
+		return nil, fmt.Errorf("could not launch browser: %w", err)
 	}
 	defer browser.Close()
 
 	context, err := browser.NewContext()
 	if err != nil {
-		return "", fmt.Errorf("could not create browser context: %w", err)
+		return nil, fmt.Errorf("could not create browser context: %w", err)
 	}
 	page, err := context.NewPage()
 	if err != nil {
-		return "", fmt.Errorf("could not create page: %w", err)
+		return nil, fmt.Errorf("could not create page: %w", err)
 	}
 	if _, err := page.Goto(baseURL, playwright.PageGotoOptions{Timeout: playwright.Float(45000)}); err != nil {
-		return "", fmt.Errorf("could not navigate to baseURL: %w", err)
+		return nil, fmt.Errorf("could not navigate to baseURL: %w", err)
 	}
 
 	// Wait for network to be idle (optional, can adjust as needed)
@@ -100,46 +338,72 @@ func getCookiesFromBaseURL(baseURL string) (string, error) {
 	// Extract cookies
 	cookies, err := context.Cookies()
 	if err != nil {
-		return "", fmt.Errorf("could not get cookies: %w", err)
+		return nil, fmt.Errorf("could not get cookies: %w", err)
 	}
 
-	// Format cookies for HTTP header
-	var cookiePairs []string
+	// Keep domain, path and expiry, so the jar sends each cookie only where it belongs
+	result := make([]*http.Cookie, 0, len(cookies))
 	for _, c := range cookies {
-		cookiePairs = append(cookiePairs, c.Name+"="+c.Value)
+		cookie := &http.Cookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   c.Domain,
+			Path:     c.Path,
+			Secure:   c.Secure,
+			HttpOnly: c.HttpOnly,
+		}
+		// Session cookies have no expiry (-1)
+		if c.Expires > 0 {
+			cookie.Expires = time.Unix(int64(c.Expires), 0)
+		}
+		result = append(result, cookie)
 	}
-	cookieHeader := strings.Join(cookiePairs, "; ")
-	return cookieHeader, nil
+	return result, nil
 }
 
-func (c *CookiesManager) IsExpired() bool {
-	decodedValue, err := extractAccessTokenExpirationTime(c.cookies)
+// Credentials returns the tokens carried by the current cookies
+func (c *CookiesManager) Credentials() (Credentials, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return ParseCredentials(c.cookies)
+}
+
+// warnConflicts logs when the expiry cookies and the JWT claims disagree, once per new set of cookies
+func warnConflicts(cookies string) {
+	credentials, err := ParseCredentials(cookies)
 	if err != nil {
-		return true
+		fmt.Printf("⚠️  Cannot tell when the cookies expire, they will be refreshed on every request: %v\n", err)
 	}
-	// Parse the time (layout: 2006-01-02T15:04:05Z)
-	t, err := time.Parse(time.RFC3339, decodedValue)
+	for _, conflict := range credentials.Conflicts {
+		fmt.Printf("⚠️  Cookie expiry mismatch: %s, using the earliest\n", conflict)
+	}
+}
+
+func (c *CookiesManager) IsExpired() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.isExpired()
+}
+
+// isExpired checks the current cookies; c.mu must be held
+func (c *CookiesManager) isExpired() bool {
+	expiration, err := accessTokenExpiration(c.cookies)
 	if err != nil {
 		return true
 	}
-	// Convert to local time
-	localTime := t.Local()
 	// If now is after the expiration time, it's expired
-	return c.timeProvider.Now().After(localTime)
+	return c.timeProvider.Now().After(expiration)
 }
 
-func extractAccessTokenExpirationTime(cookies string) (string, error) {
-	cookiePairs := strings.SplitSeq(cookies, "; ")
-	for pair := range cookiePairs {
-		if after, ok := strings.CutPrefix(pair, "accessTokenExpirationTime="); ok {
-			value := after
-			// URL decode the value
-			decodedValue, err := url.QueryUnescape(value)
-			if err != nil {
-				return "", err
-			}
-			return decodedValue, nil
-		}
+// accessTokenExpiration returns when the access token carried by cookies expires
+func accessTokenExpiration(cookies string) (time.Time, error) {
+	credentials, err := ParseCredentials(cookies)
+	if err != nil {
+		return time.Time{}, err
 	}
-	return "", fmt.Errorf("accessTokenExpirationTime not found")
+	return credentials.AccessTokenExpiresAt, nil
+}
+
+func extractAccessTokenExpirationTime(cookies string) (string, error) {
+	return cookieValue(cookies, "accessTokenExpirationTime")
 }
@@ -0,0 +1,141 @@
+package header
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// blockingFetch counts the browser launches and holds each one until release is closed
+func blockingFetch(calls *atomic.Int32, release <-chan struct{}, cookies string, err error) func(string) ([]*http.Cookie, error) {
+	return func(string) ([]*http.Cookie, error) {
+		calls.Add(1)
+		<-release
+		return parseCookieHeader(cookies), err
+	}
+}
+
+func TestCookiesManager_SingleFlight(t *testing.T) {
+	exp, _ := time.Parse(time.RFC3339, EXP)
+	fresh := "accessTokenExpirationTime=2025-09-20T02%3A29%3A07Z"
+	tests := []struct {
+		name           string
+		fetchErr       error
+		expectsCookies string
+	}{
+		{
+			name:           "concurrent callers share one refresh",
+			expectsCookies: fresh,
+		},
+		{
+			name:     "concurrent callers share the refresh error",
+			fetchErr: errors.New("browser unavailable"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls atomic.Int32
+			release := make(chan struct{})
+			cm := &CookiesManager{
+				cookies:      COOKIE,
+				timeProvider: mockTimeProvider{now: exp.Add(time.Second)},
+				fetchCookies: blockingFetch(&calls, release, fresh, tc.fetchErr),
+				stop:         make(chan struct{}),
+			}
+
+			const callers = 50
+			var wg sync.WaitGroup
+			results := make([]string, callers)
+			errs := make([]error, callers)
+			for i := range callers {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					results[i], errs[i] = cm.GetCookies()
+				}()
+			}
+			// Let every caller find the cookies expired before the refresh completes
+			assert.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+			time.Sleep(50 * time.Millisecond)
+			close(release)
+			wg.Wait()
+
+			assert.Equal(t, int32(1), calls.Load())
+			for i := range callers {
+				if tc.fetchErr != nil {
+					assert.ErrorIs(t, errs[i], tc.fetchErr)
+				} else {
+					assert.NoError(t, errs[i])
+					assert.Equal(t, tc.expectsCookies, results[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCookiesManager_ReadersDuringRefresh(t *testing.T) {
+	exp, _ := time.Parse(time.RFC3339, EXP)
+	var calls atomic.Int32
+	release := make(chan struct{})
+	cm := &CookiesManager{
+		cookies:      COOKIE,
+		credential:   credentialKey(COOKIE),
+		timeProvider: mockTimeProvider{now: exp.Add(-time.Hour)},
+		fetchCookies: blockingFetch(&calls, release, COOKIE, nil),
+		stop:         make(chan struct{}),
+	}
+
+	// A background refresh must not block callers while the current cookies are valid
+	done := make(chan error)
+	go func() { done <- cm.refresh() }()
+	assert.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cookies, err := cm.GetCookies()
+			assert.NoError(t, err)
+			assert.Equal(t, COOKIE, cookies)
+			assert.False(t, cm.IsExpired())
+		}()
+	}
+	wg.Wait()
+
+	close(release)
+	assert.NoError(t, <-done)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestCookiesManager_Invalidate(t *testing.T) {
+	exp, _ := time.Parse(time.RFC3339, EXP)
+	fresh := "accessTokenExpirationTime=2025-09-20T02%3A29%3A07Z"
+	var calls atomic.Int32
+	release := make(chan struct{})
+	close(release)
+	cm := &CookiesManager{
+		cookies:      COOKIE,
+		credential:   credentialKey(COOKIE),
+		timeProvider: mockTimeProvider{now: exp.Add(-time.Hour)},
+		fetchCookies: blockingFetch(&calls, release, fresh, nil),
+		stop:         make(chan struct{}),
+	}
+
+	// Valid cookies revoked by the server are renewed, whatever the other cookies sent along
+	assert.NoError(t, cm.Invalidate(strings.Replace(COOKIE, "hasLayout=true", "hasLayout=false", 1)))
+	cookies, err := cm.GetCookies()
+	assert.NoError(t, err)
+	assert.Equal(t, fresh, cookies)
+
+	// A late caller reporting the old cookies does not trigger another refresh
+	assert.NoError(t, cm.Invalidate(COOKIE))
+	assert.Equal(t, int32(1), calls.Load())
+}
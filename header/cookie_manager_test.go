@@ -1,10 +1,15 @@
 package header
 
 import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 const (
@@ -110,3 +115,80 @@ func TestExtractAccessTokenExpirationTime(t *testing.T) {
 		})
 	}
 }
+
+func TestCookiesManager_Cache(t *testing.T) {
+	exp, _ := time.Parse(time.RFC3339, EXP)
+	fresh := "accessTokenExpirationTime=2025-09-20T02%3A29%3A07Z"
+	tests := []struct {
+		name          string
+		cached        string
+		now           time.Time
+		expectsFetch  bool
+		expectsCookie string
+	}{
+		{
+			name:          "valid cache is reused",
+			cached:        COOKIE,
+			now:           exp.Add(-time.Hour),
+			expectsFetch:  false,
+			expectsCookie: COOKIE,
+		},
+		{
+			name:          "expired cache is refreshed",
+			cached:        COOKIE,
+			now:           exp.Add(time.Second),
+			expectsFetch:  true,
+			expectsCookie: fresh,
+		},
+		{
+			name:          "missing cache is filled",
+			now:           exp,
+			expectsFetch:  true,
+			expectsCookie: fresh,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cacheFile := filepath.Join(t.TempDir(), "go-extractor", "cookies.json")
+			if tc.cached != "" {
+				require.NoError(t, writeCache(cacheFile, parseCookieHeader(tc.cached), tc.now))
+			}
+			fetched := false
+			fetch := func(string) ([]*http.Cookie, error) {
+				fetched = true
+				return parseCookieHeader(fresh), nil
+			}
+
+			cm, err := newCookiesManager(CookiesManagerOptions{
+				CacheFile:    cacheFile,
+				TimeProvider: mockTimeProvider{now: tc.now},
+			}, fetch)
+			require.NoError(t, err)
+			defer cm.Close()
+
+			assert.Equal(t, tc.expectsFetch, fetched)
+			cookies, err := cm.GetCookies()
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectsCookie, cookies)
+
+			info, err := os.Stat(cacheFile)
+			require.NoError(t, err)
+			assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+			data, err := os.ReadFile(cacheFile)
+			require.NoError(t, err)
+			assert.Contains(t, string(data), tc.expectsCookie)
+		})
+	}
+}
+
+func TestCookiesManager_CorruptedCache(t *testing.T) {
+	cacheFile := filepath.Join(t.TempDir(), "cookies.json")
+	require.NoError(t, os.WriteFile(cacheFile, []byte("{not json"), 0600))
+
+	cm, err := newCookiesManager(CookiesManagerOptions{CacheFile: cacheFile}, func(string) ([]*http.Cookie, error) {
+		return nil, errors.New("browser unavailable")
+	})
+	assert.Nil(t, cm)
+	assert.ErrorContains(t, err, "browser unavailable")
+}
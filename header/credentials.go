@@ -0,0 +1,127 @@
+package header
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// expiryTolerance is the largest gap between two expiry sources still considered in agreement
+const expiryTolerance = time.Minute
+
+// Credentials are the tokens carried by the cookies and their validity
+type Credentials struct {
+	// AccessToken is the microservicesToken JWT
+	AccessToken string
+	// RefreshToken is the microservicesRefreshToken JWT
+	RefreshToken string
+	// AccessTokenNotBefore is the nbf claim of the access token, zero when unknown
+	AccessTokenNotBefore time.Time
+	// AccessTokenExpiresAt comes from accessTokenExpirationTime, else from the exp claim of the access token
+	AccessTokenExpiresAt time.Time
+	// RefreshTokenExpiresAt comes from refreshTokenExpirationTime, else from the exp claim of the refresh token
+	RefreshTokenExpiresAt time.Time
+	// Conflicts describes the expiry sources that disagree; the earliest expiry is kept
+	Conflicts []string
+}
+
+// ParseCredentials reads the tokens out of a cookie header. It fails when no source
+// gives the access token expiry, still returning whatever else was found.
+func ParseCredentials(cookies string) (Credentials, error) {
+	var c Credentials
+	c.AccessToken, _ = cookieValue(cookies, "microservicesToken")
+	c.RefreshToken, _ = cookieValue(cookies, "microservicesRefreshToken")
+
+	access := jwtClaims(c.AccessToken)
+	c.AccessTokenNotBefore = access.notBefore()
+	expiresAt, conflict := pickExpiry("access token", cookieTime(cookies, "accessTokenExpirationTime"), access.expiresAt())
+	if conflict != "" {
+		c.Conflicts = append(c.Conflicts, conflict)
+	}
+	c.AccessTokenExpiresAt = expiresAt
+
+	refresh := jwtClaims(c.RefreshToken)
+	c.RefreshTokenExpiresAt, conflict = pickExpiry("refresh token", cookieTime(cookies, "refreshTokenExpirationTime"), refresh.expiresAt())
+	if conflict != "" {
+		c.Conflicts = append(c.Conflicts, conflict)
+	}
+
+	if c.AccessTokenExpiresAt.IsZero() {
+		return c, errors.New("no access token expiry: accessTokenExpirationTime and microservicesToken are missing or malformed")
+	}
+	return c, nil
+}
+
+// pickExpiry keeps the cookie expiry, falling back to the JWT one, and the earliest of both when they disagree
+func pickExpiry(name string, fromCookie, fromClaims time.Time) (time.Time, string) {
+	switch {
+	case fromCookie.IsZero():
+		return fromClaims, ""
+	case fromClaims.IsZero():
+		return fromCookie, ""
+	}
+	gap := fromCookie.Sub(fromClaims).Abs()
+	if gap <= expiryTolerance {
+		return fromCookie, ""
+	}
+	conflict := fmt.Sprintf("%s expiry cookie says %s but the JWT exp claim says %s", name, fromCookie.Format(time.RFC3339), fromClaims.Format(time.RFC3339))
+	if fromClaims.Before(fromCookie) {
+		return fromClaims, conflict
+	}
+	return fromCookie, conflict
+}
+
+// cookieTime parses an RFC 3339 cookie value, zero when missing or malformed
+func cookieTime(cookies, name string) time.Time {
+	value, err := cookieValue(cookies, name)
+	if err != nil {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}
+	}
+	return t.Local()
+}
+
+// claims are the registered JWT claims used to tell when a token is valid
+type claims struct {
+	NotBefore int64 `json:"nbf"`
+	ExpiresAt int64 `json:"exp"`
+}
+
+func (c claims) notBefore() time.Time {
+	return unixTime(c.NotBefore)
+}
+
+func (c claims) expiresAt() time.Time {
+	return unixTime(c.ExpiresAt)
+}
+
+func unixTime(seconds int64) time.Time {
+	if seconds == 0 {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0)
+}
+
+// jwtClaims decodes the payload of token without checking its signature: the API checks it,
+// we only need to know when to refresh. Malformed tokens give zero claims.
+func jwtClaims(token string) claims {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return claims{}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return claims{}
+	}
+	var c claims
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return claims{}
+	}
+	return c
+}
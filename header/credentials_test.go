@@ -0,0 +1,103 @@
+package header
+
+import (
+	"encoding/base64"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeJWT builds an unsigned token with the given nbf and exp claims
+func fakeJWT(nbf, exp time.Time) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	payload := base64.RawURLEncoding.EncodeToString(fmt.Appendf(nil, `{"nbf":%d,"exp":%d,"iss":"Prod"}`, nbf.Unix(), exp.Unix()))
+	return header + "." + payload + ".signature"
+}
+
+func TestParseCredentials(t *testing.T) {
+	exp, _ := time.Parse(time.RFC3339, EXP)
+	refreshExp, _ := time.Parse(time.RFC3339, "2025-09-25T14:29:07Z")
+	nbf := time.Unix(1758205747, 0)
+	early := exp.Add(-time.Hour)
+
+	tests := []struct {
+		name            string
+		cookies         string
+		expectsAccess   time.Time
+		expectsRefresh  time.Time
+		expectsConflict int
+		expectsErr      bool
+	}{
+		{
+			name:           "cookies and claims agree",
+			cookies:        COOKIE,
+			expectsAccess:  exp,
+			expectsRefresh: refreshExp,
+		},
+		{
+			name:          "claims replace a missing expiry cookie",
+			cookies:       "microservicesToken=" + fakeJWT(nbf, exp),
+			expectsAccess: exp,
+		},
+		{
+			name:          "claims replace a malformed expiry cookie",
+			cookies:       "microservicesToken=" + fakeJWT(nbf, exp) + "; accessTokenExpirationTime=not-a-date",
+			expectsAccess: exp,
+		},
+		{
+			name:          "cookie used when the token is not a JWT",
+			cookies:       "microservicesToken=opaque; accessTokenExpirationTime=2025-09-19T02%3A29%3A07Z",
+			expectsAccess: exp,
+		},
+		{
+			name:            "earliest expiry kept when the sources disagree",
+			cookies:         "microservicesToken=" + fakeJWT(nbf, early) + "; accessTokenExpirationTime=2025-09-19T02%3A29%3A07Z",
+			expectsAccess:   early,
+			expectsConflict: 1,
+		},
+		{
+			name:       "no expiry source",
+			cookies:    "microservicesToken=opaque; foo=bar",
+			expectsErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			credentials, err := ParseCredentials(tc.cookies)
+			if tc.expectsErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, tc.expectsAccess.Equal(credentials.AccessTokenExpiresAt), "access token expires at %s", credentials.AccessTokenExpiresAt)
+			assert.True(t, tc.expectsRefresh.Equal(credentials.RefreshTokenExpiresAt), "refresh token expires at %s", credentials.RefreshTokenExpiresAt)
+			assert.Len(t, credentials.Conflicts, tc.expectsConflict)
+			assert.NotEmpty(t, credentials.AccessToken)
+		})
+	}
+}
+
+func TestParseCredentials_Tokens(t *testing.T) {
+	credentials, err := ParseCredentials(COOKIE)
+	require.NoError(t, err)
+	assert.Contains(t, credentials.AccessToken, "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9.")
+	assert.Contains(t, credentials.RefreshToken, "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9.")
+	assert.NotEqual(t, credentials.AccessToken, credentials.RefreshToken)
+	assert.Equal(t, time.Unix(1758205747, 0), credentials.AccessTokenNotBefore)
+}
+
+func TestCookiesManager_IsExpiredFromClaims(t *testing.T) {
+	exp, _ := time.Parse(time.RFC3339, EXP)
+	cm := &CookiesManager{
+		cookies:      "microservicesToken=" + fakeJWT(exp.Add(-time.Hour), exp),
+		timeProvider: mockTimeProvider{now: exp.Add(-time.Minute)},
+	}
+	assert.False(t, cm.IsExpired())
+
+	cm.timeProvider = mockTimeProvider{now: exp.Add(time.Minute)}
+	assert.True(t, cm.IsExpired())
+}
@@ -1,10 +1,15 @@
 package header
 
-func GetHeaders(cookiesManager *CookiesManager) (map[string]string, error) {
-	cookies, err := cookiesManager.GetCookies()
+// GetHeaders returns the headers authenticating a request. The cookie header is left out
+// when the provider has a jar: the client built by NewHTTPClient sends its cookies.
+func GetHeaders(provider CredentialProvider) (map[string]string, error) {
+	cookies, err := provider.GetCookies()
 	if err != nil {
 		return nil, err
 	}
+	if _, ok := provider.(JarProvider); ok || cookies == "" {
+		return map[string]string{}, nil
+	}
 	return map[string]string{
 		"cookie": cookies,
 	}, nil
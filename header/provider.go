@@ -0,0 +1,61 @@
+package header
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// CredentialProvider supplies the cookies authenticating requests to the API
+// Implementations: CookiesManager (Playwright), StaticProvider, NoAuthProvider
+type CredentialProvider interface {
+	GetCookies() (string, error)
+}
+
+// Invalidator is implemented by the providers able to renew cookies the server rejected
+type Invalidator interface {
+	// Invalidate discards the rejected cookies, so that the next GetCookies returns new ones
+	Invalidate(rejected string) error
+}
+
+// StaticProvider serves a fixed cookie string, e.g. copied from a browser session
+type StaticProvider struct {
+	cookies string
+}
+
+func NewStaticProvider(cookies string) *StaticProvider {
+	return &StaticProvider{cookies: strings.TrimSpace(cookies)}
+}
+
+// NewStaticProviderFromFile reads the cookie string from path
+func NewStaticProviderFromFile(path string) (*StaticProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cookies file: %w", err)
+	}
+	p := NewStaticProvider(string(data))
+	if p.cookies == "" {
+		return nil, fmt.Errorf("cookies file %s is empty", path)
+	}
+	return p, nil
+}
+
+// NewStaticProviderFromEnv reads the cookie string from the env var name
+func NewStaticProviderFromEnv(name string) (*StaticProvider, error) {
+	p := NewStaticProvider(os.Getenv(name))
+	if p.cookies == "" {
+		return nil, fmt.Errorf("%s is not set", name)
+	}
+	return p, nil
+}
+
+func (s *StaticProvider) GetCookies() (string, error) {
+	return s.cookies, nil
+}
+
+// NoAuthProvider sends no cookies, for tests and offline runs against stub servers
+type NoAuthProvider struct{}
+
+func (NoAuthProvider) GetCookies() (string, error) {
+	return "", nil
+}
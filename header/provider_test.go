@@ -0,0 +1,76 @@
+package header
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaticProvider(t *testing.T) {
+	dir := t.TempDir()
+	cookiesFile := filepath.Join(dir, "cookies.txt")
+	require.NoError(t, os.WriteFile(cookiesFile, []byte("a=1; b=2\n"), 0600))
+	emptyFile := filepath.Join(dir, "empty.txt")
+	require.NoError(t, os.WriteFile(emptyFile, []byte("\n"), 0600))
+	t.Setenv("TEST_COOKIES", "c=3")
+
+	tests := []struct {
+		name       string
+		newFunc    func() (*StaticProvider, error)
+		expectsErr bool
+		expects    string
+	}{
+		{
+			name:    "from file",
+			newFunc: func() (*StaticProvider, error) { return NewStaticProviderFromFile(cookiesFile) },
+			expects: "a=1; b=2",
+		},
+		{
+			name:       "missing file",
+			newFunc:    func() (*StaticProvider, error) { return NewStaticProviderFromFile(filepath.Join(dir, "missing.txt")) },
+			expectsErr: true,
+		},
+		{
+			name:       "empty file",
+			newFunc:    func() (*StaticProvider, error) { return NewStaticProviderFromFile(emptyFile) },
+			expectsErr: true,
+		},
+		{
+			name:    "from env",
+			newFunc: func() (*StaticProvider, error) { return NewStaticProviderFromEnv("TEST_COOKIES") },
+			expects: "c=3",
+		},
+		{
+			name:       "env not set",
+			newFunc:    func() (*StaticProvider, error) { return NewStaticProviderFromEnv("TEST_COOKIES_UNSET") },
+			expectsErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := tc.newFunc()
+			if tc.expectsErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			cookies, err := p.GetCookies()
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expects, cookies)
+		})
+	}
+}
+
+func TestGetHeaders(t *testing.T) {
+	headers, err := GetHeaders(NewStaticProvider("a=1"))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"cookie": "a=1"}, headers)
+
+	headers, err = GetHeaders(NoAuthProvider{})
+	assert.NoError(t, err)
+	assert.Empty(t, headers)
+}
@@ -0,0 +1,68 @@
+package header
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// refreshTimeout bounds a refresh token exchange, so a hung endpoint falls back to Playwright quickly
+const refreshTimeout = 15 * time.Second
+
+// errNoRefreshToken means the cookies cannot be refreshed without a browser
+var errNoRefreshToken = errors.New("no valid microservicesRefreshToken")
+
+// exchangeRefreshToken posts the current cookies, which carry microservicesRefreshToken, to refreshURL
+// and merges the cookies set by the response into them. The result must hold a new, valid access token.
+func exchangeRefreshToken(client *http.Client, refreshURL string, cookies []*http.Cookie, now time.Time) ([]*http.Cookie, error) {
+	sent := flattenCookies(cookies)
+	if !canExchangeRefreshToken(sent, now) {
+		return nil, errNoRefreshToken
+	}
+
+	req, err := http.NewRequest(http.MethodPost, refreshURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("cookie", sent)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("refresh token exchange returned status %d", resp.StatusCode)
+	}
+
+	refreshed := mergeCookies(cookies, resp.Cookies(), now)
+	expiration, err := accessTokenExpiration(flattenCookies(refreshed))
+	if err != nil {
+		return nil, fmt.Errorf("refresh token exchange returned no access token expiration: %w", err)
+	}
+	if !now.Before(expiration) {
+		return nil, fmt.Errorf("refresh token exchange returned an access token expired at %s", expiration)
+	}
+	return refreshed, nil
+}
+
+// canExchangeRefreshToken checks that cookies hold a refresh token which has not expired yet
+func canExchangeRefreshToken(cookies string, now time.Time) bool {
+	credentials, _ := ParseCredentials(cookies)
+	return credentials.RefreshToken != "" && now.Before(credentials.RefreshTokenExpiresAt)
+}
+
+// cookieValue returns the URL decoded value of the named cookie
+func cookieValue(cookies, name string) (string, error) {
+	for pair := range strings.SplitSeq(cookies, "; ") {
+		if value, ok := strings.CutPrefix(pair, name+"="); ok {
+			return url.QueryUnescape(value)
+		}
+	}
+	return "", fmt.Errorf("%s not found", name)
+}
@@ -0,0 +1,147 @@
+package header
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	EXPIRED_ACCESS   = "microservicesToken=old; microservicesRefreshToken=refresh; accessTokenExpirationTime=2025-09-19T02%3A29%3A07Z; refreshTokenExpirationTime=2025-09-25T14%3A29%3A07Z; hasLayout=true"
+	EXPIRED_REFRESH  = "microservicesToken=old; microservicesRefreshToken=refresh; accessTokenExpirationTime=2025-09-19T02%3A29%3A07Z; refreshTokenExpirationTime=2025-09-19T03%3A00%3A00Z"
+	BROWSER_COOKIES  = "microservicesToken=browser; accessTokenExpirationTime=2025-09-20T02%3A29%3A07Z"
+	REFRESHED_ACCESS = "microservicesToken=new; microservicesRefreshToken=refresh; accessTokenExpirationTime=2025-09-19T14%3A29%3A07Z; refreshTokenExpirationTime=2025-09-25T14%3A29%3A07Z; hasLayout=true"
+)
+
+// refreshStub answers the refresh endpoint with the given status, setting a new access token on success
+func refreshStub(t *testing.T, status int, calls *int) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		assert.Equal(t, http.MethodPost, r.Method)
+		token, err := r.Cookie("microservicesRefreshToken")
+		if assert.NoError(t, err) {
+			assert.Equal(t, "refresh", token.Value)
+		}
+		if status == http.StatusOK {
+			http.SetCookie(w, &http.Cookie{Name: "microservicesToken", Value: "new"})
+			http.SetCookie(w, &http.Cookie{Name: "accessTokenExpirationTime", Value: "2025-09-19T14%3A29%3A07Z"})
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestCookiesManager_RefreshToken(t *testing.T) {
+	now, _ := time.Parse(time.RFC3339, "2025-09-19T03:30:00Z")
+	tests := []struct {
+		name           string
+		cookies        string
+		status         int
+		browserErr     error
+		expectsCalls   int
+		expectsBrowser bool
+		expectsCookies string
+		expectsErr     bool
+	}{
+		{
+			name:           "refresh token exchanged over HTTP",
+			cookies:        EXPIRED_ACCESS,
+			status:         http.StatusOK,
+			expectsCalls:   1,
+			expectsCookies: REFRESHED_ACCESS,
+		},
+		{
+			name:           "exchange rejected falls back to the browser",
+			cookies:        EXPIRED_ACCESS,
+			status:         http.StatusUnauthorized,
+			expectsCalls:   1,
+			expectsBrowser: true,
+			expectsCookies: BROWSER_COOKIES,
+		},
+		{
+			name:           "exchange without a new access token falls back to the browser",
+			cookies:        EXPIRED_ACCESS,
+			status:         http.StatusNoContent,
+			expectsCalls:   1,
+			expectsBrowser: true,
+			expectsCookies: BROWSER_COOKIES,
+		},
+		{
+			name:           "expired refresh token goes straight to the browser",
+			cookies:        EXPIRED_REFRESH,
+			status:         http.StatusOK,
+			expectsCalls:   0,
+			expectsBrowser: true,
+			expectsCookies: BROWSER_COOKIES,
+		},
+		{
+			name:           "browser failure is returned",
+			cookies:        EXPIRED_ACCESS,
+			status:         http.StatusInternalServerError,
+			browserErr:     errors.New("browser unavailable"),
+			expectsCalls:   1,
+			expectsBrowser: true,
+			expectsErr:     true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			calls := 0
+			server := refreshStub(t, tc.status, &calls)
+			cacheFile := filepath.Join(t.TempDir(), "cookies.json")
+			require.NoError(t, writeCache(cacheFile, parseCookieHeader(tc.cookies), now))
+
+			browser := false
+			cm, err := newCookiesManager(CookiesManagerOptions{
+				CacheFile:    cacheFile,
+				RefreshURL:   server.URL,
+				TimeProvider: mockTimeProvider{now: now},
+			}, func(string) ([]*http.Cookie, error) {
+				browser = true
+				return parseCookieHeader(BROWSER_COOKIES), tc.browserErr
+			})
+
+			assert.Equal(t, tc.expectsCalls, calls)
+			assert.Equal(t, tc.expectsBrowser, browser)
+			if tc.expectsErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			defer cm.Close()
+			cookies, err := cm.GetCookies()
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectsCookies, cookies)
+		})
+	}
+}
+
+func TestMergeCookies(t *testing.T) {
+	now := time.Date(2025, 9, 19, 3, 30, 0, 0, time.UTC)
+	cookies := []*http.Cookie{
+		{Name: "a", Value: "1", Domain: ".example.com", Path: "/"},
+		{Name: "b", Value: "2", Domain: ".example.com", Path: "/"},
+		{Name: "gone", Value: "x"},
+	}
+	merged := mergeCookies(cookies, []*http.Cookie{
+		{Name: "b", Value: "3"},
+		{Name: "c", Value: "4"},
+		{Name: "gone", MaxAge: -1},
+		{Name: "stale", Value: "5", Expires: now.Add(-time.Hour)},
+	}, now)
+
+	assert.Equal(t, "a=1; b=3; c=4", flattenCookies(merged))
+	// Updates without attributes keep the scope of the cookie they replace
+	assert.Equal(t, ".example.com", merged[1].Domain)
+	// The original cookies are left untouched
+	assert.Equal(t, "a=1; b=2; gone=x", flattenCookies(cookies))
+}
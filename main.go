@@ -2,87 +2,179 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
-	"time"
+	"os/signal"
+	"strings"
+	"syscall"
+)
 
-	"github.com/paologalligit/go-extractor/constant"
-	"github.com/paologalligit/go-extractor/fetchshowings"
-	"github.com/paologalligit/go-extractor/header"
-	"github.com/paologalligit/go-extractor/persistence"
-	"github.com/paologalligit/go-extractor/settimers"
+// Exit codes returned by run
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitUsage   = 2
 )
 
+// runFunc executes a command once its flags have been parsed.
+// args holds the positional arguments left after flag parsing.
+// ctx is cancelled on SIGINT or SIGTERM.
+type runFunc func(ctx context.Context, args []string) error
+
+// command is a single entry of the CLI command tree
+type command struct {
+	name    string
+	aliases []string
+	summary string
+	usage   string
+	// setup registers the command's flags and returns the function that runs it
+	setup func(fs *flag.FlagSet) runFunc
+}
+
+// usageError marks errors caused by invalid flags or arguments
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+func usageErrorf(format string, args ...any) error {
+	return &usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+func commands() []*command {
+	return []*command{
+		fetchCommand(),
+		catalogCommand(),
+		trackCommand(),
+		serveCommand(),
+		planCommand(),
+		exportCommand(),
+		importCommand(),
+		initdbCommand(),
+		configCommand(),
+		fakeapiCommand(),
+	}
+}
+
 func main() {
-	if len(os.Args) < 2 {
-		fmt.Println("Usage: go run main.go [all|today|initdb] [options]")
-		os.Exit(1)
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run dispatches args to the matching command and returns the process exit code
+func run(args []string, stdout, stderr io.Writer) int {
+	cmds := commands()
+	if len(args) == 0 {
+		printUsage(stderr, cmds)
+		return exitUsage
 	}
 
-	cookiesManager, err := header.New()
-	if err != nil {
-		fmt.Printf("error getting cookies: %v\n", err)
-		os.Exit(1)
+	name := args[0]
+	if name == "help" || name == "-h" || name == "--help" {
+		if len(args) > 1 {
+			if cmd := findCommand(cmds, args[1]); cmd != nil {
+				fs := newFlagSet(cmd, stdout)
+				cmd.setup(fs)
+				fs.Usage()
+				return exitOK
+			}
+		}
+		printUsage(stdout, cmds)
+		return exitOK
 	}
 
-	// Parse command line flags
-	maxGoroutines := flag.Int("workers", 10, "Number of concurrent workers")
-	requestDelay := flag.Int("delay", 100, "Delay between requests in milliseconds")
-	flag.Parse()
-
-	switch os.Args[len(os.Args)-1] {
-	case "all":
-		fmt.Printf("Configuration: Using %d workers with %dms delay between requests\n", *maxGoroutines, *requestDelay)
-
-		timestamp := time.Now().Format("20060102_150405")
-		filename := fmt.Sprintf("%s_%s.json", "showings", timestamp)
-		opt := &fetchshowings.FetchShowingsOptions{
-			MaxGoroutines:  *maxGoroutines,
-			RequestDelay:   *requestDelay,
-			ShowingUrl:     constant.SHOWINGS_URL,
-			OutputFileName: filename,
-			CookiesManager: cookiesManager,
+	cmd := findCommand(cmds, name)
+	if cmd == nil {
+		fmt.Fprintf(stderr, "Unknown command: %s\n\n", name)
+		printUsage(stderr, cmds)
+		return exitUsage
+	}
+
+	fs := newFlagSet(cmd, stderr)
+	runCmd := cmd.setup(fs)
+	positional, err := parseInterspersed(fs, args[1:])
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
 		}
-		if err := fetchshowings.RunFetchShowings(opt); err != nil {
-			fmt.Printf("error running fetch showings: %v\n", err)
-			os.Exit(1)
+		return exitUsage
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		// Restore the default behaviour so a second signal kills the process
+		stop()
+	}()
+
+	if err := runCmd(ctx, positional); err != nil {
+		var uerr *usageError
+		if errors.As(err, &uerr) {
+			fmt.Fprintf(stderr, "%s: %v\n\n", cmd.name, err)
+			fs.Usage()
+			return exitUsage
 		}
-	case "today":
-		pool, err := persistence.NewPostgresPool(context.Background())
-		if err != nil {
-			fmt.Printf("error creating postgres pool: %v\n", err)
-			os.Exit(1)
+		fmt.Fprintf(stderr, "%s: %v\n", cmd.name, err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// parseInterspersed parses fs allowing flags after positional arguments,
+// e.g. "config print --format json", and returns the positional arguments
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
 		}
-		defer pool.Close()
-		fmt.Println("Postgres pool created...")
-
-		opt := &settimers.SettimersOptions{
-			CookiesManager: cookiesManager,
-			Persistence:    persistence.NewPostgresPersistence(pool),
-			MaxGoroutines:  *maxGoroutines,
-			RequestDelay:   *requestDelay,
+		if fs.NArg() == 0 {
+			return positional, nil
 		}
-		if err := settimers.RunSeatTimers(opt); err != nil {
-			fmt.Printf("error running seat timers: %v\n", err)
-			os.Exit(1)
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+func findCommand(cmds []*command, name string) *command {
+	for _, cmd := range cmds {
+		if cmd.name == name {
+			return cmd
 		}
-	case "initdb":
-		pool, err := persistence.NewPostgresPool(context.Background())
-		if err != nil {
-			fmt.Printf("error creating postgres pool: %v\n", err)
-			os.Exit(1)
+		for _, alias := range cmd.aliases {
+			if alias == name {
+				return cmd
+			}
 		}
-		defer pool.Close()
-		if err := persistence.InitPostgresSchema(context.Background(), pool); err != nil {
-			fmt.Printf("error initializing postgres schema: %v\n", err)
-			os.Exit(1)
+	}
+	return nil
+}
+
+func newFlagSet(cmd *command, out io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.SetOutput(out)
+	fs.Usage = func() {
+		fmt.Fprintf(out, "Usage: go-extractor %s\n\n%s\n", cmd.usage, cmd.summary)
+		if len(cmd.aliases) > 0 {
+			fmt.Fprintf(out, "\nAliases: %s\n", strings.Join(cmd.aliases, ", "))
 		}
-		fmt.Println("Postgres schema initialized")
-		os.Exit(0)
-	default:
-		fmt.Println("Unknown command:", os.Args[1])
-		fmt.Println("Usage: go run main.go [all|today|initdb] [options]")
-		os.Exit(1)
+		fmt.Fprintln(out, "\nOptions:")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+func printUsage(out io.Writer, cmds []*command) {
+	fmt.Fprintln(out, "Usage: go-extractor <command> [options]")
+	fmt.Fprintln(out, "\nCommands:")
+	for _, cmd := range cmds {
+		fmt.Fprintf(out, "  %-10s %s\n", cmd.name, cmd.summary)
 	}
+	fmt.Fprintln(out, "\nRun 'go-extractor help <command>' for details on a command.")
 }
@@ -1,9 +1,141 @@
 package main
 
 import (
+	"bytes"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
-func TestMain(t *testing.T) {
-	main()
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		exitCode int
+		stdout   string
+		stderr   string
+	}{
+		{
+			name:     "no command",
+			args:     nil,
+			exitCode: exitUsage,
+			stderr:   "Usage: go-extractor <command> [options]",
+		},
+		{
+			name:     "help",
+			args:     []string{"help"},
+			exitCode: exitOK,
+			stdout:   "Commands:",
+		},
+		{
+			name:     "help for a command",
+			args:     []string{"help", "fetch"},
+			exitCode: exitOK,
+			stdout:   "-workers",
+		},
+		{
+			name:     "unknown command",
+			args:     []string{"nope"},
+			exitCode: exitUsage,
+			stderr:   "Unknown command: nope",
+		},
+		{
+			name:     "unknown flag",
+			args:     []string{"fetch", "--nope"},
+			exitCode: exitUsage,
+			stderr:   "flag provided but not defined: -nope",
+		},
+		{
+			name:     "invalid flag value is rejected before fetching cookies",
+			args:     []string{"fetch", "--workers=0"},
+			exitCode: exitUsage,
+			stderr:   "requests.workers must be positive",
+		},
+		{
+			name:     "alias resolves to the command",
+			args:     []string{"today", "--rps=-1"},
+			exitCode: exitUsage,
+			stderr:   "track: invalid configuration: requests.rate_limit rates must not be negative",
+		},
+		{
+			name:     "track an invalid date",
+			args:     []string{"track", "--date=15/09/2025"},
+			exitCode: exitUsage,
+			stderr:   "--date must be YYYY-MM-DD",
+		},
+		{
+			name:     "record and replay together",
+			args:     []string{"fetch", "--record=cassette", "--replay=cassette"},
+			exitCode: exitUsage,
+			stderr:   "--record and --replay are mutually exclusive",
+		},
+		{
+			name:     "replay from a missing cassette",
+			args:     []string{"fetch", "--replay=does-not-exist"},
+			exitCode: exitFailure,
+			stderr:   "has no recorded interaction",
+		},
+		{
+			name:     "unknown site",
+			args:     []string{"fetch", "--site=nope"},
+			exitCode: exitUsage,
+			stderr:   `--site "nope" is not configured, the sites are [thespacecinema]`,
+		},
+		{
+			name:     "invalid build time",
+			args:     []string{"serve", "--build-at=8am"},
+			exitCode: exitUsage,
+			stderr:   "serve.build_at must be a HH:MM time",
+		},
+		{
+			name:     "missing config file",
+			args:     []string{"config", "print", "--config=does-not-exist.yaml"},
+			exitCode: exitUsage,
+			stderr:   "failed to open config file",
+		},
+		{
+			name:     "config without subcommand",
+			args:     []string{"config"},
+			exitCode: exitUsage,
+			stderr:   "expected subcommand: print",
+		},
+		{
+			name:     "catalog without subcommand",
+			args:     []string{"catalog"},
+			exitCode: exitUsage,
+			stderr:   "expected subcommand: refresh",
+		},
+		{
+			name:     "fake API without scenario",
+			args:     []string{"fakeapi"},
+			exitCode: exitUsage,
+			stderr:   "--scenario is required",
+		},
+		{
+			name:     "fake API with an invalid scenario",
+			args:     []string{"fakeapi", "--scenario=does-not-exist.yaml"},
+			exitCode: exitUsage,
+			stderr:   "failed to read scenario",
+		},
+		{
+			name:     "unexpected positional arguments",
+			args:     []string{"initdb", "extra"},
+			exitCode: exitUsage,
+			stderr:   "unexpected arguments",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := run(tc.args, &stdout, &stderr)
+			assert.Equal(t, tc.exitCode, code)
+			if tc.stdout != "" {
+				assert.Contains(t, stdout.String(), tc.stdout)
+			}
+			if tc.stderr != "" {
+				assert.Contains(t, stderr.String(), tc.stderr)
+			}
+		})
+	}
 }
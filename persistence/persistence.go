@@ -3,12 +3,17 @@ package persistence
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/paologalligit/go-extractor/entities"
+	"github.com/paologalligit/go-extractor/site"
 )
 
 // Persistence defines the interface for logging seat counts
@@ -53,9 +58,10 @@ func NewPostgresPersistence(pool *pgxpool.Pool) *PostgresPersistence {
 
 func (p *PostgresPersistence) WriteSessionSeats(ctx context.Context, entry entities.SeatLogEntry) error {
 	_, err := p.Pool.Exec(ctx, `
-		INSERT INTO session (cinema_name, film_name, session_id, seats, logged_at, start_hour)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO session (site, cinema_name, film_name, session_id, seats, logged_at, start_hour)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
 	`,
+		siteOf(entry.Site),
 		entry.CinemaName,
 		entry.FilmName,
 		entry.SessionId,
@@ -68,3 +74,184 @@ func (p *PostgresPersistence) WriteSessionSeats(ctx context.Context, entry entit
 	}
 	return nil
 }
+
+// SessionFilter selects the seat log entries to read. Zero values match everything.
+type SessionFilter struct {
+	From       time.Time // logged at or after From
+	To         time.Time // logged before To
+	Site       string
+	CinemaName string
+	FilmName   string
+	SessionId  string
+}
+
+// Match reports whether entry is selected by the filter
+func (f SessionFilter) Match(entry entities.SeatLogEntry) bool {
+	if !f.From.IsZero() && entry.LoggedAt.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !entry.LoggedAt.Before(f.To) {
+		return false
+	}
+	if f.Site != "" && siteOf(entry.Site) != f.Site {
+		return false
+	}
+	if f.CinemaName != "" && !strings.EqualFold(entry.CinemaName, f.CinemaName) {
+		return false
+	}
+	if f.FilmName != "" && !strings.EqualFold(entry.FilmName, f.FilmName) {
+		return false
+	}
+	if f.SessionId != "" && entry.SessionId != f.SessionId {
+		return false
+	}
+	return true
+}
+
+// Reader streams the stored seat counts in the order they were logged, calling fn for each entry
+// Implementations: FilePersistence, PostgresPersistence
+type Reader interface {
+	ReadSessionSeats(ctx context.Context, filter SessionFilter, fn func(entry entities.SeatLogEntry) error) error
+}
+
+func (f *FilePersistence) ReadSessionSeats(ctx context.Context, filter SessionFilter, fn func(entry entities.SeatLogEntry) error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := os.Open(f.FilePath)
+	if err != nil {
+		return fmt.Errorf("error opening log file: %w", err)
+	}
+	defer file.Close()
+
+	dec := json.NewDecoder(file)
+	for line := 1; ; line++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var entry entities.SeatLogEntry
+		if err := dec.Decode(&entry); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("error reading log entry %d: %w", line, err)
+		}
+		entry.Site = siteOf(entry.Site)
+		if !filter.Match(entry) {
+			continue
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+}
+
+func (p *PostgresPersistence) ReadSessionSeats(ctx context.Context, filter SessionFilter, fn func(entry entities.SeatLogEntry) error) error {
+	var conditions []string
+	var args []any
+	add := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if !filter.From.IsZero() {
+		add("logged_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		add("logged_at < $%d", filter.To)
+	}
+	if filter.Site != "" {
+		add("site = $%d", filter.Site)
+	}
+	// Names are compared ignoring case like SessionFilter.Match, not as LIKE patterns
+	if filter.CinemaName != "" {
+		add("lower(cinema_name) = lower($%d)", filter.CinemaName)
+	}
+	if filter.FilmName != "" {
+		add("lower(film_name) = lower($%d)", filter.FilmName)
+	}
+	if filter.SessionId != "" {
+		add("session_id = $%d", filter.SessionId)
+	}
+	query := `SELECT site, cinema_name, film_name, session_id, seats, logged_at, to_char(start_hour, 'HH24:MI') FROM session`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY logged_at, id"
+
+	// Rows are streamed from the server one at a time
+	rows, err := p.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("error querying seat log entries: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var entry entities.SeatLogEntry
+		if err := rows.Scan(&entry.Site, &entry.CinemaName, &entry.FilmName, &entry.SessionId, &entry.Seats, &entry.LoggedAt, &entry.StartHour); err != nil {
+			return fmt.Errorf("error scanning seat log entry: %w", err)
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error reading seat log entries: %w", err)
+	}
+	return nil
+}
+
+// Importer loads historical data idempotently: each method reports whether the row was new
+// or already stored. Implementations: PostgresPersistence
+type Importer interface {
+	ImportSessionSeats(ctx context.Context, entry entities.SeatLogEntry) (bool, error)
+	ImportSessionSnapshot(ctx context.Context, snapshot entities.SessionSnapshot) (bool, error)
+}
+
+func (p *PostgresPersistence) ImportSessionSeats(ctx context.Context, entry entities.SeatLogEntry) (bool, error) {
+	tag, err := p.Pool.Exec(ctx, `
+		INSERT INTO session (site, cinema_name, film_name, session_id, seats, logged_at, start_hour)
+		SELECT $1, $2, $3, $4, $5, $6, $7
+		WHERE NOT EXISTS (SELECT 1 FROM session WHERE site = $1 AND session_id = $4 AND logged_at = $6)
+	`,
+		siteOf(entry.Site),
+		entry.CinemaName,
+		entry.FilmName,
+		entry.SessionId,
+		entry.Seats,
+		entry.LoggedAt,
+		entry.StartHour,
+	)
+	if err != nil {
+		return false, fmt.Errorf("error importing seat log entry: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (p *PostgresPersistence) ImportSessionSnapshot(ctx context.Context, snapshot entities.SessionSnapshot) (bool, error) {
+	tag, err := p.Pool.Exec(ctx, `
+		INSERT INTO showing (site, cinema_id, cinema_name, film_id, film_name, session_id, start_time, seats, total_seats, fetched_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (site, cinema_id, session_id, fetched_at) DO NOTHING
+	`,
+		siteOf(snapshot.Site),
+		snapshot.CinemaId,
+		snapshot.CinemaName,
+		snapshot.FilmId,
+		snapshot.FilmName,
+		snapshot.Session.SessionId,
+		snapshot.StartAt,
+		snapshot.Session.Seats,
+		snapshot.Session.TotalSeats,
+		snapshot.FetchedAt,
+	)
+	if err != nil {
+		return false, fmt.Errorf("error importing session snapshot: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// siteOf returns the site of a record, the default one for the records written before sites existed
+func siteOf(name string) string {
+	if name == "" {
+		return site.DefaultName
+	}
+	return name
+}
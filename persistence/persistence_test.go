@@ -0,0 +1,103 @@
+package persistence
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/paologalligit/go-extractor/entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testDatabaseURL names the env var pointing the Postgres tests to a scratch database; they are skipped without it
+const testDatabaseURL = "GOEXTRACTOR_TEST_DATABASE_URL"
+
+func TestReadSessionSeats(t *testing.T) {
+	// A site of its own keeps the rows of each run apart in a shared database
+	site := "test-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	loggedAt := time.Date(2025, 9, 15, 20, 0, 0, 0, time.UTC)
+	entries := []entities.SeatLogEntry{
+		{Site: site, CinemaName: "Torino", FilmName: "Film A", SessionId: "1", Seats: 10, StartHour: "20:30", LoggedAt: loggedAt},
+		{Site: site, CinemaName: "Torino_2", FilmName: "100% Film", SessionId: "2", Seats: 20, StartHour: "21:00", LoggedAt: loggedAt.Add(time.Minute)},
+		{Site: site, CinemaName: "TorinoX2", FilmName: "1000 Films", SessionId: "3", Seats: 30, StartHour: "21:30", LoggedAt: loggedAt.Add(2 * time.Minute)},
+	}
+	tests := []struct {
+		name         string
+		filter       SessionFilter
+		expectsSeats []int
+	}{
+		{
+			name:         "cinema ignoring case",
+			filter:       SessionFilter{Site: site, CinemaName: "TORINO"},
+			expectsSeats: []int{10},
+		},
+		{
+			name:         "underscore is not a wildcard",
+			filter:       SessionFilter{Site: site, CinemaName: "torino_2"},
+			expectsSeats: []int{20},
+		},
+		{
+			name:         "percent is not a wildcard",
+			filter:       SessionFilter{Site: site, FilmName: "100% film"},
+			expectsSeats: []int{20},
+		},
+		{
+			name:   "pattern matching nothing literally",
+			filter: SessionFilter{Site: site, FilmName: "%"},
+		},
+	}
+
+	readers := map[string]Reader{"file": newTestFilePersistence(t, entries)}
+	if p, ok := newTestPostgresPersistence(t, entries); ok {
+		readers["postgres"] = p
+	}
+	for name, reader := range readers {
+		for _, tc := range tests {
+			t.Run(name+"/"+tc.name, func(t *testing.T) {
+				var seats []int
+				err := reader.ReadSessionSeats(context.Background(), tc.filter, func(entry entities.SeatLogEntry) error {
+					seats = append(seats, entry.Seats)
+					return nil
+				})
+				require.NoError(t, err)
+				assert.Equal(t, tc.expectsSeats, seats)
+			})
+		}
+	}
+}
+
+func newTestFilePersistence(t *testing.T, entries []entities.SeatLogEntry) *FilePersistence {
+	f := NewFilePersistence(filepath.Join(t.TempDir(), "seats.jsonl"))
+	for _, entry := range entries {
+		require.NoError(t, f.WriteSessionSeats(context.Background(), entry))
+	}
+	return f
+}
+
+func newTestPostgresPersistence(t *testing.T, entries []entities.SeatLogEntry) (*PostgresPersistence, bool) {
+	dsn := os.Getenv(testDatabaseURL)
+	if dsn == "" {
+		t.Logf("%s is not set, comparing with Postgres skipped", testDatabaseURL)
+		return nil, false
+	}
+	ctx := context.Background()
+	pool, err := NewPostgresPool(ctx, dsn)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+	// The schema file is read from the root of the repository
+	t.Chdir("..")
+	require.NoError(t, InitPostgresSchema(ctx, pool))
+
+	p := NewPostgresPersistence(pool)
+	for _, entry := range entries {
+		require.NoError(t, p.WriteSessionSeats(ctx, entry))
+	}
+	t.Cleanup(func() {
+		pool.Exec(context.Background(), "DELETE FROM session WHERE site = $1", entries[0].Site)
+	})
+	return p, true
+}
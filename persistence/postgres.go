@@ -7,15 +7,12 @@ import (
 	"strings"
 
 	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/joho/godotenv"
 )
 
 // NewPostgresPool creates a new pgx connection pool
-func NewPostgresPool(ctx context.Context) (*pgxpool.Pool, error) {
-	_ = godotenv.Load() // Load .env if present, ignore error
-	dsn := os.Getenv("DATABASE_URL")
+func NewPostgresPool(ctx context.Context, dsn string) (*pgxpool.Pool, error) {
 	if dsn == "" {
-		return nil, fmt.Errorf("DATABASE_URL is not set")
+		return nil, fmt.Errorf("database.url (DATABASE_URL) is not set")
 	}
 	pool, err := pgxpool.New(ctx, dsn)
 	if err != nil {
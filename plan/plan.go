@@ -0,0 +1,207 @@
+package plan
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/paologalligit/go-extractor/catalog"
+	"github.com/paologalligit/go-extractor/client"
+	"github.com/paologalligit/go-extractor/config"
+	"github.com/paologalligit/go-extractor/entities"
+	"github.com/paologalligit/go-extractor/settimers"
+	"github.com/paologalligit/go-extractor/site"
+	"github.com/paologalligit/go-extractor/team"
+	"github.com/paologalligit/go-extractor/utils"
+)
+
+// Output formats
+const (
+	FormatTable = "table"
+	FormatJSON  = "json"
+)
+
+type PlanOptions struct {
+	// Credentials are only needed when the day's sessions file does not exist yet
+	Client        *client.ExtractorClient
+	MaxGoroutines int
+	Site          site.Site
+	// FilesPath holds the cinemas of Site
+	FilesPath string
+	// CatalogTTL is how long the stored cinemas are used before asking the API again
+	CatalogTTL time.Duration
+	Sampling   config.Sampling
+	// Day is the showing date, in the time zone of Site
+	Day    time.Time
+	Format string
+	Output io.Writer
+	// now defaults to the wall clock, it is replaced in tests
+	now func() time.Time
+}
+
+// CinemaPlan groups the planned sessions of one cinema
+type CinemaPlan struct {
+	CinemaId   string                    `json:"cinemaId"`
+	CinemaName string                    `json:"cinemaName"`
+	Sessions   []entities.PlannedSession `json:"sessions"`
+}
+
+// HourTotal counts the samples fired within one hour
+type HourTotal struct {
+	Hour    time.Time `json:"hour"`
+	Samples int       `json:"samples"`
+}
+
+// Plan is the sampling schedule of a showing date
+type Plan struct {
+	Site      string       `json:"site"`
+	Date      string       `json:"date"`
+	Cinemas   []CinemaPlan `json:"cinemas"`
+	PerHour   []HourTotal  `json:"perHour"`
+	Scheduled int          `json:"scheduled"`
+	Skipped   int          `json:"skipped"`
+}
+
+// NeedsFetch reports whether planning day on siteName requires calling the showings endpoint
+func NeedsFetch(siteName string, day time.Time) bool {
+	_, err := os.Stat(settimers.TodayFile(siteName, day))
+	return os.IsNotExist(err)
+}
+
+// RunPlan builds the sampling schedule of options.Day the same way the track command does,
+// without calling the seats endpoint, and prints it
+func RunPlan(ctx context.Context, options *PlanOptions) error {
+	today := options.Day.Format("2006-01-02")
+	todayFile := settimers.TodayFile(options.Site.Name, options.Day)
+	wm := &team.SessionTeamWorkingMaterial{
+		MaxGoroutines: options.MaxGoroutines,
+		Endpoints:     options.Site.Endpoints(),
+		Site:          options.Site.Name,
+		Location:      options.Site.Location,
+		Sampling:      options.Sampling,
+	}
+	if NeedsFetch(options.Site.Name, options.Day) {
+		if options.Client == nil {
+			return fmt.Errorf("%s not found and no cookies to fetch the showings", todayFile)
+		}
+		cache := catalog.New(options.FilesPath, options.CatalogTTL, options.Client, wm.Endpoints)
+		if _, err := cache.Ensure(ctx, catalog.Cinemas); err != nil {
+			return fmt.Errorf("failed to fetch cinemas: %w", err)
+		}
+		cinemaIds, regionData, err := utils.GetCinemaIds(options.FilesPath)
+		if err != nil {
+			return fmt.Errorf("error getting cinema ids: %w", err)
+		}
+		wm.Client = options.Client
+		wm.CinemaIds = cinemaIds
+		wm.RegionData = regionData
+	}
+
+	now := time.Now
+	if options.now != nil {
+		now = options.now
+	}
+	st := team.NewSessionTeam(options.MaxGoroutines, wm)
+	planned, err := st.Plan(ctx, today, todayFile, planningTime(options.Day, now()))
+	if err != nil {
+		return err
+	}
+
+	p := buildPlan(today, planned)
+	p.Site = options.Site.Name
+	switch options.Format {
+	case FormatJSON:
+		enc := json.NewEncoder(options.Output)
+		enc.SetIndent("", "  ")
+		return enc.Encode(p)
+	case FormatTable:
+		return writeTable(options.Output, p)
+	default:
+		return fmt.Errorf("unknown format %q", options.Format)
+	}
+}
+
+// planningTime is the time the schedule of day is seen at: now when day is today in its time zone,
+// else the start of day, so that another date is planned as a whole
+func planningTime(day, now time.Time) time.Time {
+	if now.In(day.Location()).Format("2006-01-02") == day.Format("2006-01-02") {
+		return now
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+}
+
+func buildPlan(today string, planned []entities.PlannedSession) *Plan {
+	p := &Plan{Date: today}
+	byCinema := make(map[string]*CinemaPlan)
+	perHour := make(map[time.Time]int)
+	for _, session := range planned {
+		cinema, ok := byCinema[session.CinemaId]
+		if !ok {
+			cinema = &CinemaPlan{CinemaId: session.CinemaId, CinemaName: session.CinemaName}
+			byCinema[session.CinemaId] = cinema
+		}
+		cinema.Sessions = append(cinema.Sessions, session)
+		if session.Skipped {
+			p.Skipped++
+			continue
+		}
+		p.Scheduled++
+		perHour[session.FireAt.Truncate(time.Hour)]++
+	}
+
+	for _, cinema := range byCinema {
+		sort.SliceStable(cinema.Sessions, func(i, j int) bool {
+			return cinema.Sessions[i].StartAt.Before(cinema.Sessions[j].StartAt)
+		})
+		p.Cinemas = append(p.Cinemas, *cinema)
+	}
+	sort.Slice(p.Cinemas, func(i, j int) bool {
+		if p.Cinemas[i].CinemaName != p.Cinemas[j].CinemaName {
+			return p.Cinemas[i].CinemaName < p.Cinemas[j].CinemaName
+		}
+		return p.Cinemas[i].CinemaId < p.Cinemas[j].CinemaId
+	})
+	for hour, samples := range perHour {
+		p.PerHour = append(p.PerHour, HourTotal{Hour: hour, Samples: samples})
+	}
+	sort.Slice(p.PerHour, func(i, j int) bool {
+		return p.PerHour[i].Hour.Before(p.PerHour[j].Hour)
+	})
+	return p
+}
+
+func writeTable(out io.Writer, p *Plan) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Sampling plan for %s on %s (jitter is drawn again on each run)\n", p.Date, p.Site)
+	for _, cinema := range p.Cinemas {
+		fmt.Fprintf(w, "\n%s (%s)\n", cinema.CinemaName, cinema.CinemaId)
+		fmt.Fprintln(w, "  SESSION\tFILM\tSTART\tFIRES AT\tJITTER\tSTATUS")
+		for _, s := range cinema.Sessions {
+			fireAt, jitter := "-", "-"
+			if !s.FireAt.IsZero() {
+				fireAt = s.FireAt.Format("01-02 15:04:05")
+				jitter = s.Jitter.Round(time.Second).String()
+			}
+			status := "scheduled"
+			if s.Skipped {
+				status = "skipped"
+			}
+			if s.Reason != "" {
+				status += ": " + s.Reason
+			}
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\t%s\n", s.Session.SessionId, s.FilmName, s.Session.StartHour, fireAt, jitter, status)
+		}
+	}
+
+	fmt.Fprintln(w, "\nSamples per hour")
+	for _, total := range p.PerHour {
+		fmt.Fprintf(w, "  %s\t%d\n", total.Hour.Format("01-02 15:00"), total.Samples)
+	}
+	fmt.Fprintf(w, "\nScheduled: %d, skipped: %d\n", p.Scheduled, p.Skipped)
+	return w.Flush()
+}
@@ -0,0 +1,169 @@
+package plan
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/paologalligit/go-extractor/config"
+	"github.com/paologalligit/go-extractor/entities"
+	"github.com/paologalligit/go-extractor/settimers"
+	"github.com/paologalligit/go-extractor/site"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanningTime(t *testing.T) {
+	rome, err := time.LoadLocation("Europe/Rome")
+	require.NoError(t, err)
+	day := time.Date(2025, 9, 15, 0, 0, 0, 0, rome)
+	tests := []struct {
+		name    string
+		now     time.Time
+		expects time.Time
+	}{
+		{
+			name:    "today",
+			now:     time.Date(2025, 9, 15, 18, 0, 0, 0, rome),
+			expects: time.Date(2025, 9, 15, 18, 0, 0, 0, rome),
+		},
+		{
+			name:    "today in the time zone of the site only",
+			now:     time.Date(2025, 9, 14, 22, 30, 0, 0, time.UTC),
+			expects: time.Date(2025, 9, 14, 22, 30, 0, 0, time.UTC),
+		},
+		{
+			name:    "the day before",
+			now:     time.Date(2025, 9, 14, 18, 0, 0, 0, rome),
+			expects: day,
+		},
+		{
+			name:    "the day after",
+			now:     time.Date(2025, 9, 16, 9, 0, 0, 0, rome),
+			expects: day,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.True(t, tc.expects.Equal(planningTime(day, tc.now)), "got %s", planningTime(day, tc.now))
+		})
+	}
+}
+
+func TestBuildPlan(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2025, 9, 15, hour, minute, 0, 0, time.UTC)
+	}
+	planned := func(cinemaId, cinemaName, sessionId string, startAt, fireAt time.Time, skipped bool) entities.PlannedSession {
+		return entities.PlannedSession{
+			ScheduledSession: entities.ScheduledSession{CinemaId: cinemaId, CinemaName: cinemaName, Session: entities.Session{SessionId: sessionId}},
+			StartAt:          startAt,
+			FireAt:           fireAt,
+			Skipped:          skipped,
+		}
+	}
+	late := planned("1030", "Vimercate", "3", at(21, 0), at(21, 12), false)
+	early := planned("1030", "Vimercate", "1", at(18, 0), at(18, 12), true)
+	evening := planned("1030", "Vimercate", "2", at(20, 30), at(20, 42), false)
+	torino := planned("1018", "Torino", "4", at(20, 50), at(21, 2), false)
+	sameName := planned("1019", "Torino", "5", at(21, 10), at(21, 22), false)
+
+	p := buildPlan("2025-09-15", []entities.PlannedSession{late, sameName, early, torino, evening})
+
+	assert.Equal(t, "2025-09-15", p.Date)
+	assert.Equal(t, []CinemaPlan{
+		{CinemaId: "1018", CinemaName: "Torino", Sessions: []entities.PlannedSession{torino}},
+		{CinemaId: "1019", CinemaName: "Torino", Sessions: []entities.PlannedSession{sameName}},
+		{CinemaId: "1030", CinemaName: "Vimercate", Sessions: []entities.PlannedSession{early, evening, late}},
+	}, p.Cinemas)
+	assert.Equal(t, []HourTotal{{Hour: at(20, 0), Samples: 1}, {Hour: at(21, 0), Samples: 3}}, p.PerHour, "skipped sessions are not sampled")
+	assert.Equal(t, 4, p.Scheduled)
+	assert.Equal(t, 1, p.Skipped)
+}
+
+func TestRunPlan(t *testing.T) {
+	rome, err := time.LoadLocation("Europe/Rome")
+	require.NoError(t, err)
+	day := time.Date(2025, 9, 15, 0, 0, 0, 0, rome)
+	testSite := site.Site{Name: "test", Location: rome}
+	// The sessions file is read from the working directory, no API call is made
+	t.Chdir(t.TempDir())
+	showings := []entities.ShowingResult{
+		{Movie: "Film A", FilmId: "F1", CinemaId: "1030", CinemaName: "Vimercate", ShowingGroups: []entities.ShowingGroup{{Sessions: []entities.Session{
+			{SessionId: "1", StartTime: "2025-09-15T18:00:00"},
+			{SessionId: "2", StartTime: "2025-09-15T21:00:00"},
+		}}}},
+		{Movie: "Film B", FilmId: "F2", CinemaId: "1018", CinemaName: "Torino", ShowingGroups: []entities.ShowingGroup{{Sessions: []entities.Session{
+			{SessionId: "3", StartTime: "2025-09-15T20:30:00"},
+			{SessionId: "4", StartTime: "2025-09-15T01:00:00"},
+		}}}},
+	}
+	data, err := json.Marshal(showings)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(settimers.TodayFile(testSite.Name, day), data, 0644))
+
+	sampling := config.Default().Sampling
+	sampling.JitterMin, sampling.JitterMax = 0, 0
+	sampling.CinemaOffsets = nil
+	newOptions := func(now time.Time, format string, out *bytes.Buffer) *PlanOptions {
+		return &PlanOptions{
+			Site:     testSite,
+			Sampling: sampling,
+			Day:      day,
+			Format:   format,
+			Output:   out,
+			now:      func() time.Time { return now },
+		}
+	}
+
+	t.Run("table of today", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, RunPlan(context.Background(), newOptions(time.Date(2025, 9, 15, 20, 0, 0, 0, rome), FormatTable, &out)))
+
+		assert.Equal(t, `Sampling plan for 2025-09-15 on test (jitter is drawn again on each run)
+
+Torino (1018)
+  SESSION  FILM    START  FIRES AT        JITTER  STATUS
+  3        Film B  20:30  09-15 20:42:00  0s      scheduled
+  4        Film B  01:00  09-16 01:12:00  0s      scheduled: after-midnight rollover
+
+Vimercate (1030)
+  SESSION  FILM    START  FIRES AT        JITTER  STATUS
+  1        Film A  18:00  09-15 18:12:00  0s      skipped: already started
+  2        Film A  21:00  09-15 21:12:00  0s      scheduled
+
+Samples per hour
+  09-15 20:00  1
+  09-15 21:00  1
+  09-16 01:00  1
+
+Scheduled: 3, skipped: 1
+`, out.String())
+	})
+
+	t.Run("json of another date", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, RunPlan(context.Background(), newOptions(time.Date(2025, 9, 14, 20, 0, 0, 0, rome), FormatJSON, &out)))
+
+		var p Plan
+		require.NoError(t, json.Unmarshal(out.Bytes(), &p))
+		assert.Equal(t, "test", p.Site)
+		assert.Equal(t, "2025-09-15", p.Date)
+		assert.Equal(t, 4, p.Scheduled, "the sessions of another date are all planned")
+		assert.Equal(t, 0, p.Skipped)
+		require.Len(t, p.Cinemas, 2)
+		assert.Equal(t, "Torino", p.Cinemas[0].CinemaName)
+		assert.Equal(t, "Vimercate", p.Cinemas[1].CinemaName)
+		require.Len(t, p.PerHour, 4)
+		assert.True(t, time.Date(2025, 9, 15, 18, 0, 0, 0, rome).Equal(p.PerHour[0].Hour))
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		var out bytes.Buffer
+		assert.Error(t, RunPlan(context.Background(), newOptions(time.Date(2025, 9, 15, 20, 0, 0, 0, rome), "csv", &out)))
+	})
+}
@@ -0,0 +1,333 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrRequiredFieldMissing: a response lacks a field tagged schema:"required" in the entities, or has it with another type
+var ErrRequiredFieldMissing = errors.New("required field missing")
+
+// DriftKind tells how a response differs from the expected schema
+type DriftKind string
+
+const (
+	// Unknown: the response has a field the entities do not declare
+	Unknown DriftKind = "unknown"
+	// Missing: the response lacks a declared field
+	Missing DriftKind = "missing"
+	// TypeChanged: the JSON type of a field is not the declared one
+	TypeChanged DriftKind = "type changed"
+)
+
+// Drift is one difference between a response and the schema of the struct it is decoded into.
+// Path names the field from the root, with [] for the elements of an array, like result.seatRows[].columns.
+type Drift struct {
+	Kind     DriftKind
+	Path     string
+	Expected string
+	Got      string
+	Required bool
+}
+
+func (d Drift) String() string {
+	switch d.Kind {
+	case Unknown:
+		return fmt.Sprintf("unknown field %s (%s)", d.Path, d.Got)
+	case Missing:
+		if d.Required {
+			return fmt.Sprintf("missing required field %s", d.Path)
+		}
+		return fmt.Sprintf("missing field %s", d.Path)
+	default:
+		return fmt.Sprintf("field %s changed type: expected %s, got %s", d.Path, d.Expected, d.Got)
+	}
+}
+
+// Breaking reports whether the values decoded despite d are wrong: a required field is missing or changed type
+func (d Drift) Breaking() bool {
+	return d.Required && d.Kind != Unknown
+}
+
+// Check compares the JSON body with the struct v points to, following its json tags.
+// Fields tagged schema:"-" are filled locally and never expected in a response; fields tagged
+// schema:"required" must be present; the others may be left out when tagged omitempty. JSON nulls match any type.
+// Each drift is reported once, however many elements of an array show it.
+func Check(body []byte, v any) ([]Drift, error) {
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		return nil, err
+	}
+	seen := map[Drift]bool{}
+	var drifts []Drift
+	walk(reflect.TypeOf(v), value, "", false, func(d Drift) {
+		if !seen[d] {
+			seen[d] = true
+			drifts = append(drifts, d)
+		}
+	})
+	return drifts, nil
+}
+
+func walk(t reflect.Type, value any, path string, required bool, report func(Drift)) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if value == nil {
+		return
+	}
+	expected := jsonType(t)
+	if got := jsonTypeOf(value); expected != "any" && got != expected {
+		report(Drift{Kind: TypeChanged, Path: root(path), Expected: expected, Got: got, Required: required})
+		return
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		object := value.(map[string]any)
+		declared := map[string]bool{}
+		for i := range t.NumField() {
+			field := t.Field(i)
+			name, omitempty, ok := jsonName(field)
+			if !ok {
+				continue
+			}
+			declared[name] = true
+			tag := field.Tag.Get("schema")
+			if tag == "-" {
+				continue
+			}
+			fieldRequired := tag == "required"
+			fieldValue, present := object[name]
+			if !present {
+				if fieldRequired || !omitempty {
+					report(Drift{Kind: Missing, Path: join(path, name), Expected: jsonType(field.Type), Required: fieldRequired})
+				}
+				continue
+			}
+			walk(field.Type, fieldValue, join(path, name), fieldRequired, report)
+		}
+		for name, fieldValue := range object {
+			if !declared[name] {
+				report(Drift{Kind: Unknown, Path: join(path, name), Got: jsonTypeOf(fieldValue)})
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for _, element := range value.([]any) {
+			walk(t.Elem(), element, path+"[]", required, report)
+		}
+	case reflect.Map:
+		for _, element := range value.(map[string]any) {
+			walk(t.Elem(), element, path+"{}", required, report)
+		}
+	}
+}
+
+// jsonName returns the JSON name of a struct field, false when encoding/json skips it
+func jsonName(field reflect.StructField) (string, bool, bool) {
+	if !field.IsExported() {
+		return "", false, false
+	}
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false, false
+	}
+	name, options, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = field.Name
+	}
+	return name, strings.Contains(options, "omitempty"), true
+}
+
+// jsonType names the JSON type a Go type decodes from
+func jsonType(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map:
+		return "object"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	default:
+		return "any"
+	}
+}
+
+// jsonTypeOf names the JSON type of a value decoded into any
+func jsonTypeOf(value any) string {
+	switch value.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	default:
+		return "null"
+	}
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func root(path string) string {
+	if path == "" {
+		return "(root)"
+	}
+	return path
+}
+
+// Report aggregates the drifts of the responses of a run by endpoint. It is safe for concurrent use;
+// a nil Report records nothing.
+type Report struct {
+	mu        sync.Mutex
+	endpoints map[string]*endpointReport
+}
+
+type endpointReport struct {
+	responses int
+	drifts    map[Drift]int
+}
+
+func NewReport() *Report {
+	return &Report{endpoints: map[string]*endpointReport{}}
+}
+
+// Record adds the drifts of one response of endpoint, logging each drift the first time it is seen
+func (r *Report) Record(endpoint string, drifts []Drift) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.endpoints[endpoint]
+	if !ok {
+		e = &endpointReport{drifts: map[Drift]int{}}
+		r.endpoints[endpoint] = e
+	}
+	e.responses++
+	for _, d := range drifts {
+		if e.drifts[d] == 0 {
+			icon := "🧬"
+			if d.Breaking() {
+				icon = "🚨"
+			}
+			fmt.Printf("%s Schema drift on %s: %s\n", icon, endpoint, d)
+		}
+		e.drifts[d]++
+	}
+}
+
+// Take returns the drifts recorded so far and starts r over, so that a long-running command reports
+// each period on its own. A drift seen again afterwards is logged again.
+func (r *Report) Take() *Report {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	taken := &Report{endpoints: r.endpoints}
+	r.endpoints = map[string]*endpointReport{}
+	return taken
+}
+
+// HasDrifts reports whether any response showed a drift
+func (r *Report) HasDrifts() bool {
+	if r == nil {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range r.endpoints {
+		if len(e.drifts) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Print writes the drifts of each endpoint with the share of the responses showing them
+func (r *Report) Print(w io.Writer) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, endpoint := range r.sortedEndpoints() {
+		e := r.endpoints[endpoint]
+		if len(e.drifts) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "🧬 Schema drift on %s:\n", endpoint)
+		for _, d := range sortedDrifts(e.drifts) {
+			fmt.Fprintf(w, "  %s in %d of %d responses\n", d, e.drifts[d], e.responses)
+		}
+	}
+}
+
+// Err returns an error wrapping ErrRequiredFieldMissing listing the breaking drifts, nil when there are none
+func (r *Report) Err() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var errs []error
+	for _, endpoint := range r.sortedEndpoints() {
+		e := r.endpoints[endpoint]
+		for _, d := range sortedDrifts(e.drifts) {
+			if d.Breaking() {
+				errs = append(errs, fmt.Errorf("%s: %s in %d of %d responses", endpoint, d, e.drifts[d], e.responses))
+			}
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %w", ErrRequiredFieldMissing, errors.Join(errs...))
+}
+
+func (r *Report) sortedEndpoints() []string {
+	endpoints := make([]string, 0, len(r.endpoints))
+	for endpoint := range r.endpoints {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+	return endpoints
+}
+
+func sortedDrifts(drifts map[Drift]int) []Drift {
+	sorted := make([]Drift, 0, len(drifts))
+	for d := range drifts {
+		sorted = append(sorted, d)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Path != sorted[j].Path {
+			return sorted[i].Path < sorted[j].Path
+		}
+		return sorted[i].Kind < sorted[j].Kind
+	})
+	return sorted
+}
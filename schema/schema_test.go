@@ -0,0 +1,111 @@
+package schema
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/paologalligit/go-extractor/entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		v      any
+		drifts []Drift
+	}{
+		{
+			name: "matching seats",
+			body: `{"result": {"sessionOccupancy": 0.5, "seatRows": [{"columns": [{"seatStatus": 1}, null, {}]}]}}`,
+			v:    &entities.Response{},
+		},
+		{
+			name: "renamed occupancy",
+			body: `{"result": {"occupancy": 0.5, "seatRows": [{"columns": [{}]}, {"columns": [{}]}]}}`,
+			v:    &entities.Response{},
+			drifts: []Drift{
+				{Kind: Missing, Path: "result.sessionOccupancy", Expected: "number", Required: true},
+				{Kind: Unknown, Path: "result.occupancy", Got: "number"},
+			},
+		},
+		{
+			name: "retyped columns, reported once",
+			body: `{"result": {"sessionOccupancy": "50%", "seatRows": [{"columns": 3}, {"columns": 4}]}}`,
+			v:    &entities.Response{},
+			drifts: []Drift{
+				{Kind: TypeChanged, Path: "result.seatRows[].columns", Expected: "array", Got: "number", Required: true},
+				{Kind: TypeChanged, Path: "result.sessionOccupancy", Expected: "number", Got: "string", Required: true},
+			},
+		},
+		{
+			name: "showings without the locally computed fields",
+			body: `{"result": [{"filmId": "F1", "filmTitle": "A", "showingGroups": [{"date": "2025-09-15T00:00:00", "sessions": [{"sessionId": "1", "startTime": "2025-09-15T21:00:00"}]}]}]}`,
+			v:    &entities.ShowingResponse{},
+		},
+		{
+			name: "optional field missing",
+			body: `{"result": [{"filmId": "F1", "showingGroups": []}]}`,
+			v:    &entities.ShowingResponse{},
+			drifts: []Drift{
+				{Kind: Missing, Path: "result[].filmTitle", Expected: "string"},
+			},
+		},
+		{
+			name: "not an object",
+			body: `[]`,
+			v:    &entities.FilmsFile{},
+			drifts: []Drift{
+				{Kind: TypeChanged, Path: "(root)", Expected: "object", Got: "array"},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			drifts, err := Check([]byte(tc.body), tc.v)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tc.drifts, drifts)
+		})
+	}
+
+	_, err := Check([]byte(`{"result": [`), &entities.FilmsFile{})
+	assert.Error(t, err)
+}
+
+func TestReport(t *testing.T) {
+	var nilReport *Report
+	nilReport.Record("seats", []Drift{{Kind: Missing, Path: "result", Required: true}})
+	assert.NoError(t, nilReport.Err())
+
+	report := NewReport()
+	unknown := Drift{Kind: Unknown, Path: "result.occupancy", Got: "number"}
+	missing := Drift{Kind: Missing, Path: "result.sessionOccupancy", Expected: "number", Required: true}
+	report.Record("seats", []Drift{unknown})
+	report.Record("showings", nil)
+	assert.NoError(t, report.Err(), "unknown fields do not fail the run")
+
+	report.Record("seats", []Drift{unknown, missing})
+	report.Record("seats", nil)
+	err := report.Err()
+	assert.ErrorIs(t, err, ErrRequiredFieldMissing)
+	assert.ErrorContains(t, err, "seats: missing required field result.sessionOccupancy in 1 of 3 responses")
+
+	var out bytes.Buffer
+	report.Print(&out)
+	assert.Equal(t, `🧬 Schema drift on seats:
+  unknown field result.occupancy (number) in 2 of 3 responses
+  missing required field result.sessionOccupancy in 1 of 3 responses
+`, out.String())
+
+	// Taking the drifts starts the report over
+	taken := report.Take()
+	assert.True(t, taken.HasDrifts())
+	assert.ErrorIs(t, taken.Err(), ErrRequiredFieldMissing)
+	assert.False(t, report.HasDrifts())
+	assert.NoError(t, report.Err())
+	report.Record("seats", []Drift{unknown})
+	assert.True(t, report.HasDrifts())
+	assert.False(t, nilReport.HasDrifts())
+}
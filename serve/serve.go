@@ -0,0 +1,156 @@
+package serve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/paologalligit/go-extractor/config"
+	"github.com/paologalligit/go-extractor/settimers"
+)
+
+// maxSleep bounds each wait so wall-clock jumps (machine sleep, DST) are noticed within a minute
+const maxSleep = time.Minute
+
+type ServeOptions struct {
+	// Sites are tracked side by side, each on the clock of its time zone
+	Sites []*settimers.SettimersOptions
+	Serve config.Serve
+	// now, after and runDay default to the wall clock and settimers.RunDay; they are replaced in tests
+	now    func() time.Time
+	after  func(time.Duration) <-chan time.Time
+	runDay func(ctx context.Context, options *settimers.SettimersOptions, day time.Time) error
+}
+
+// RunServe runs the daily cycle of every site until ctx is cancelled.
+// On cancellation it waits for every running cycle to record its unsampled sessions.
+func RunServe(ctx context.Context, options *ServeOptions) error {
+	if options.now == nil {
+		options.now = time.Now
+	}
+	if options.after == nil {
+		options.after = time.After
+	}
+	if options.runDay == nil {
+		options.runDay = settimers.RunDay
+	}
+	var wg sync.WaitGroup
+	errs := make([]error, len(options.Sites))
+	for i, opt := range options.Sites {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = options.serveSite(ctx, opt)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// serveSite runs the daily cycle of one site: at the build time, local to the site, it builds the day's
+// session list and schedules its seat timers. Each day's timers keep running past midnight,
+// alongside the next day's cycle, until the day's last session has been sampled.
+func (o *ServeOptions) serveSite(ctx context.Context, opt *settimers.SettimersOptions) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	day := opt.Site.Today(o.now())
+	for {
+		buildTime, err := o.Serve.BuildTime(day)
+		if err != nil {
+			return fmt.Errorf("invalid build time: %w", err)
+		}
+		if o.now().Before(buildTime) {
+			fmt.Printf("😴 Session list of %s for %s will be built at %s\n", opt.Site.Name, day.Format("2006-01-02"), buildTime.Format(time.RFC3339))
+		}
+		if !o.sleepUntil(ctx, buildTime) {
+			fmt.Printf("🛑 Shutting down %s, waiting for running cycles...\n", opt.Site.Name)
+			return nil
+		}
+
+		wg.Add(1)
+		go func(day time.Time) {
+			defer wg.Done()
+			o.runCycle(ctx, opt, day)
+		}(day)
+		day = nextDay(day)
+	}
+}
+
+// runCycle builds the session list of a site for day and tracks it, retrying a failed build
+// until the next day's build time
+func (o *ServeOptions) runCycle(ctx context.Context, opt *settimers.SettimersOptions, day time.Time) {
+	date := opt.Site.Name + " " + day.Format("2006-01-02")
+	deadline, err := o.Serve.BuildTime(nextDay(day))
+	if err != nil {
+		fmt.Printf("❌❌ Invalid build time for %s: %v\n", date, err)
+		return
+	}
+	retry := o.Serve.RetryInterval.Duration()
+
+	fmt.Printf("📅 Starting cycle for %s\n", date)
+	for {
+		err := o.runDay(ctx, opt, day)
+		if ctx.Err() != nil {
+			// Keep the sessions file so a restart resumes the same day
+			fmt.Printf("🛑 Cycle for %s stopped\n", date)
+			return
+		}
+		if err == nil {
+			break
+		}
+		fmt.Printf("❌❌ Cycle for %s failed: %v\n", date, err)
+		if o.now().Add(retry).After(deadline) {
+			fmt.Printf("❌❌ Giving up on %s, the next cycle starts at %s\n", date, deadline.Format(time.RFC3339))
+			reportSchema(opt, date)
+			return
+		}
+		fmt.Printf("Retrying cycle for %s in %s\n", date, retry)
+		if !o.sleepUntil(ctx, o.now().Add(retry)) {
+			return
+		}
+	}
+
+	todayFile := settimers.TodayFile(opt.Site.Name, day)
+	if err := os.Remove(todayFile); err != nil && !os.IsNotExist(err) {
+		fmt.Printf("Failed to remove %s: %v\n", todayFile, err)
+	}
+	reportSchema(opt, date)
+	fmt.Printf("🏁 Cycle for %s completed\n", date)
+}
+
+// reportSchema prints the schema drifts seen by the client of a site since its previous cycle ended,
+// and starts its report over for the next day. A cycle stopped by the shutdown leaves them to the exit report.
+func reportSchema(opt *settimers.SettimersOptions, date string) {
+	report := opt.Client.Schema().Take()
+	if report.HasDrifts() {
+		fmt.Printf("🧬 Schema drifts of %s:\n", date)
+		report.Print(os.Stdout)
+	}
+	if err := report.Err(); err != nil {
+		fmt.Printf("❌❌ Schema of %s: %v\n", date, err)
+	}
+}
+
+// nextDay returns midnight of the day after day, in day's location
+func nextDay(day time.Time) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, day.Location())
+}
+
+// sleepUntil waits until the clock reaches t, returning false if ctx is cancelled first
+func (o *ServeOptions) sleepUntil(ctx context.Context, t time.Time) bool {
+	for {
+		wait := t.Sub(o.now())
+		if wait <= 0 {
+			return ctx.Err() == nil
+		}
+		select {
+		case <-o.after(min(wait, maxSleep)):
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package serve
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/paologalligit/go-extractor/client"
+	"github.com/paologalligit/go-extractor/config"
+	"github.com/paologalligit/go-extractor/header"
+	"github.com/paologalligit/go-extractor/schema"
+	"github.com/paologalligit/go-extractor/settimers"
+	"github.com/paologalligit/go-extractor/site"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// run is a call of runDay: the showing date and the time of the call
+type run struct {
+	day string
+	at  time.Time
+}
+
+// fakeClock moves forward by the whole wait on each call of after, without sleeping.
+// When cyclesBy is set, after first waits for runDay to be called for every cycle started by now,
+// so that each run is recorded at the time it was started.
+type fakeClock struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	now      time.Time
+	runs     []run
+	cyclesBy func(t time.Time) int
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	c := &fakeClock{now: now}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.cyclesBy != nil && len(c.runs) < c.cyclesBy(c.now) {
+		c.cond.Wait()
+	}
+	c.now = c.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+// record adds a call of runDay for day and returns the number of calls so far
+func (c *fakeClock) record(day time.Time) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.runs = append(c.runs, run{day: day.Format("2006-01-02"), at: c.now})
+	c.cond.Broadcast()
+	return len(c.runs)
+}
+
+// cyclesBy counts the cycles serveSite has started by t when it started at start: one per build time reached,
+// the one of the first day starting at once when its build time is past
+func cyclesBy(serve config.Serve, start time.Time) func(t time.Time) int {
+	return func(t time.Time) int {
+		n := 0
+		for day := start; ; day = nextDay(day) {
+			buildTime, _ := serve.BuildTime(day)
+			if buildTime.Before(start) {
+				buildTime = start
+			}
+			if buildTime.After(t) {
+				return n
+			}
+			n++
+		}
+	}
+}
+
+func newTestOptions(t *testing.T, clock *fakeClock, runDay func(ctx context.Context, options *settimers.SettimersOptions, day time.Time) error) (*ServeOptions, *settimers.SettimersOptions) {
+	rome, err := time.LoadLocation("Europe/Rome")
+	require.NoError(t, err)
+	opt := &settimers.SettimersOptions{
+		Client: client.NewWithOptions(header.NoAuthProvider{}, client.Options{Schema: schema.NewReport()}),
+		Site:   site.Site{Name: "test", Location: rome},
+	}
+	return &ServeOptions{
+		Sites:  []*settimers.SettimersOptions{opt},
+		Serve:  config.Serve{BuildAt: "06:00", RetryInterval: config.Duration(time.Hour)},
+		now:    clock.Now,
+		after:  clock.After,
+		runDay: runDay,
+	}, opt
+}
+
+func TestServeSite(t *testing.T) {
+	rome, err := time.LoadLocation("Europe/Rome")
+	require.NoError(t, err)
+	at := func(day string, hour, minute int) time.Time {
+		d, err := time.ParseInLocation("2006-01-02", day, rome)
+		require.NoError(t, err)
+		return time.Date(d.Year(), d.Month(), d.Day(), hour, minute, 0, 0, rome)
+	}
+	tests := []struct {
+		name        string
+		start       time.Time
+		expectsRuns []run
+	}{
+		{
+			name:  "waits for the build time",
+			start: at("2025-09-15", 5, 0),
+			expectsRuns: []run{
+				{day: "2025-09-15", at: at("2025-09-15", 6, 0)},
+				{day: "2025-09-16", at: at("2025-09-16", 6, 0)},
+			},
+		},
+		{
+			name:  "builds at once after the build time",
+			start: at("2025-09-15", 10, 0),
+			expectsRuns: []run{
+				{day: "2025-09-15", at: at("2025-09-15", 10, 0)},
+				{day: "2025-09-16", at: at("2025-09-16", 6, 0)},
+			},
+		},
+		{
+			name:  "rolls over at midnight into the next month",
+			start: at("2025-09-30", 23, 30),
+			expectsRuns: []run{
+				{day: "2025-09-30", at: at("2025-09-30", 23, 30)},
+				{day: "2025-10-01", at: at("2025-10-01", 6, 0)},
+			},
+		},
+		{
+			name:  "rolls over a 25 hours day",
+			start: at("2025-10-25", 10, 0),
+			expectsRuns: []run{
+				{day: "2025-10-25", at: at("2025-10-25", 10, 0)},
+				{day: "2025-10-26", at: at("2025-10-26", 6, 0)},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			clock := newFakeClock(tc.start)
+			options, opt := newTestOptions(t, clock, func(ctx context.Context, _ *settimers.SettimersOptions, day time.Time) error {
+				if clock.record(day) == len(tc.expectsRuns) {
+					cancel()
+				}
+				return nil
+			})
+			clock.cyclesBy = cyclesBy(options.Serve, tc.start)
+
+			assert.NoError(t, options.serveSite(ctx, opt))
+			assert.Equal(t, tc.expectsRuns, clock.runs)
+		})
+	}
+}
+
+func TestServeSite_CyclesOverlap(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	start := time.Date(2025, 9, 15, 10, 0, 0, 0, time.UTC)
+	clock := newFakeClock(start)
+	nextStarted := make(chan struct{})
+	var mu sync.Mutex
+	var finished []string
+	options, opt := newTestOptions(t, clock, func(ctx context.Context, _ *settimers.SettimersOptions, day time.Time) error {
+		if clock.record(day) == 1 {
+			// The sessions of the first day keep being sampled past midnight
+			<-nextStarted
+		} else {
+			close(nextStarted)
+			cancel()
+		}
+		mu.Lock()
+		finished = append(finished, day.Format("2006-01-02"))
+		mu.Unlock()
+		return nil
+	})
+	clock.cyclesBy = cyclesBy(options.Serve, opt.Site.Today(start))
+
+	assert.NoError(t, options.serveSite(ctx, opt))
+	assert.Equal(t, []string{"2025-09-16", "2025-09-15"}, finished)
+}
+
+func TestRunCycle(t *testing.T) {
+	failure := errors.New("showings unavailable")
+	tests := []struct {
+		name            string
+		failures        int
+		expectsAttempts int
+		expectsLast     time.Time
+	}{
+		{
+			name:            "retried after a failure",
+			failures:        1,
+			expectsAttempts: 2,
+			expectsLast:     time.Date(2025, 9, 15, 7, 0, 0, 0, time.UTC),
+		},
+		{
+			name:            "given up at the next build time",
+			failures:        100,
+			expectsAttempts: 25,
+			expectsLast:     time.Date(2025, 9, 16, 6, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			day := time.Date(2025, 9, 15, 6, 0, 0, 0, time.UTC)
+			clock := newFakeClock(day)
+			options, opt := newTestOptions(t, clock, func(ctx context.Context, _ *settimers.SettimersOptions, day time.Time) error {
+				if clock.record(day) <= tc.failures {
+					return failure
+				}
+				return nil
+			})
+
+			options.runCycle(context.Background(), opt, day)
+
+			require.Len(t, clock.runs, tc.expectsAttempts)
+			assert.Equal(t, tc.expectsLast, clock.runs[len(clock.runs)-1].at)
+		})
+	}
+}
+
+func TestRunCycle_SchemaReport(t *testing.T) {
+	missing := schema.Drift{Kind: schema.Missing, Path: "result.sessionOccupancy", Expected: "number", Required: true}
+	day := time.Date(2025, 9, 15, 6, 0, 0, 0, time.UTC)
+	clock := newFakeClock(day)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	options, opt := newTestOptions(t, clock, func(ctx context.Context, opt *settimers.SettimersOptions, day time.Time) error {
+		opt.Client.Schema().Record(client.EndpointSeats, []schema.Drift{missing})
+		if clock.record(day) == 2 {
+			cancel()
+		}
+		return nil
+	})
+	report := opt.Client.Schema()
+
+	// A completed cycle reports its drifts and the next day starts afresh
+	options.runCycle(ctx, opt, day)
+	assert.False(t, report.HasDrifts())
+	assert.NoError(t, report.Err())
+
+	// A cycle stopped by the shutdown leaves its drifts to the exit report
+	options.runCycle(ctx, opt, nextDay(day))
+	assert.ErrorIs(t, report.Err(), schema.ErrRequiredFieldMissing)
+}
+
+func TestNextDay(t *testing.T) {
+	rome, err := time.LoadLocation("Europe/Rome")
+	require.NoError(t, err)
+	tests := []struct {
+		name    string
+		day     time.Time
+		expects time.Time
+	}{
+		{
+			name:    "late evening",
+			day:     time.Date(2025, 9, 15, 23, 59, 0, 0, rome),
+			expects: time.Date(2025, 9, 16, 0, 0, 0, 0, rome),
+		},
+		{
+			name:    "end of the year",
+			day:     time.Date(2025, 12, 31, 8, 0, 0, 0, rome),
+			expects: time.Date(2026, 1, 1, 0, 0, 0, 0, rome),
+		},
+		{
+			name:    "daylight saving time starts",
+			day:     time.Date(2025, 3, 30, 0, 30, 0, 0, rome),
+			expects: time.Date(2025, 3, 31, 0, 0, 0, 0, rome),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.True(t, tc.expects.Equal(nextDay(tc.day)), "got %s", nextDay(tc.day))
+		})
+	}
+}
@@ -2,65 +2,212 @@ package settimers
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
 	"time"
 
+	"github.com/paologalligit/go-extractor/catalog"
 	"github.com/paologalligit/go-extractor/client"
-	"github.com/paologalligit/go-extractor/constant"
+	"github.com/paologalligit/go-extractor/config"
 	"github.com/paologalligit/go-extractor/entities"
-	"github.com/paologalligit/go-extractor/header"
 	"github.com/paologalligit/go-extractor/persistence"
+	"github.com/paologalligit/go-extractor/site"
 	"github.com/paologalligit/go-extractor/team"
 	"github.com/paologalligit/go-extractor/utils"
 )
 
 type SettimersOptions struct {
-	CookiesManager *header.CookiesManager
-	Persistence    persistence.Persistence
-	MaxGoroutines  int
-	RequestDelay   int
+	// Client calls the API; the seat counts stop retrying at Sampling.Deadline
+	Client        *client.ExtractorClient
+	Persistence   persistence.Persistence
+	MaxGoroutines int
+	Site          site.Site
+	// FilesPath holds the cinemas and films of Site
+	FilesPath string
+	// CatalogTTL is how long the stored cinemas are used before asking the API again
+	CatalogTTL time.Duration
+	Sampling   config.Sampling
+	// Now and Delay are the clock the timers run on, the wall clock when nil.
+	// A replay sets them to a clock fast-forwarding through the recorded day.
+	Now   func() time.Time
+	Delay team.DelayFunc
 }
 
-func RunSeatTimers(options *SettimersOptions) error {
-	today := time.Now().Format("2006-01-02")
-	todayFile := fmt.Sprintf("todaySession-%s.json", today)
-	cinemaIds, regionData, err := utils.GetCinemaIds()
+func (o *SettimersOptions) now() time.Time {
+	if o.Now == nil {
+		return time.Now()
+	}
+	return o.Now()
+}
+
+func (o *SettimersOptions) delay(d time.Duration) <-chan time.Time {
+	if o.Delay == nil {
+		return time.After(d)
+	}
+	return o.Delay(d)
+}
+
+// flushTimeout bounds the writes still in flight when the run is cancelled
+const flushTimeout = 10 * time.Second
+
+// TodayFile returns the name of the sessions file of siteName for the showing date day
+func TodayFile(siteName string, day time.Time) string {
+	return fmt.Sprintf("todaySession-%s-%s.json", siteName, day.Format("2006-01-02"))
+}
+
+// UnsampledFile returns the name of the file recording the sessions of siteName on day that were never sampled
+func UnsampledFile(siteName string, day time.Time) string {
+	return fmt.Sprintf("unsampledSessions-%s-%s.json", siteName, day.Format("2006-01-02"))
+}
+
+// RunDay samples the seats of the sessions showing on day, including the ones running past midnight,
+// and blocks until every timer has fired. When ctx is cancelled, the pending timers are dropped,
+// the writes in flight are completed, and the sessions never sampled are written to UnsampledFile.
+func RunDay(ctx context.Context, options *SettimersOptions, day time.Time) error {
+	today := day.Format("2006-01-02")
+	todayFile := TodayFile(options.Site.Name, day)
+	unsampledFile := UnsampledFile(options.Site.Name, day)
+	endpoints := options.Site.Endpoints()
+	cache := catalog.New(options.FilesPath, options.CatalogTTL, options.Client, endpoints)
+	if _, err := cache.Ensure(ctx, catalog.Cinemas); err != nil {
+		return fmt.Errorf("failed to fetch cinemas: %w", err)
+	}
+	cinemaIds, regionData, err := utils.GetCinemaIds(options.FilesPath)
 	if err != nil {
 		return fmt.Errorf("error getting cinema ids: %w", err)
 	}
 
 	wm := &team.SessionTeamWorkingMaterial{
-		RequestDelay:  options.RequestDelay,
-		Client:        client.New(options.CookiesManager),
+		Client:        options.Client,
 		MaxGoroutines: options.MaxGoroutines,
 		CinemaIds:     cinemaIds,
 		RegionData:    regionData,
+		Endpoints:     endpoints,
+		Site:          options.Site.Name,
+		Location:      options.Site.Location,
+		Sampling:      options.Sampling,
+		Delay:         options.Delay,
+		Now:           options.Now,
 	}
 
 	st := team.NewSessionTeam(options.MaxGoroutines, wm)
-	st.Run(today, todayFile, func(s entities.ScheduledSession) {
+	_, unsampled, err := st.Run(ctx, today, todayFile, func(ctx context.Context, s entities.ScheduledSession) bool {
 		// This callback is executed when the timer fires for a session
-		url := fmt.Sprintf(constant.SEATS_URL, s.CinemaId, s.Session.SessionId)
-		seatResp, err := st.WorkingMaterial.Client.CallSeats(url)
-		if err != nil {
-			fmt.Printf("❌❌ Error counting seats for session %s: %v\n", s.Session.SessionId, err)
-			return
+		return sampleSeats(ctx, options, st.WorkingMaterial.Client, endpoints.Seats(s.CinemaId, s.Session.SessionId), s)
+	})
+	if err != nil {
+		return err
+	}
+	if len(unsampled) > 0 {
+		if err := writeUnsampled(unsampledFile, unsampled); err != nil {
+			return fmt.Errorf("error recording unsampled sessions: %w", err)
 		}
-		totalSeats := seatResp.Result.SeatRows.CountSeats()
-		seatsNum := int(seatResp.Result.SessionOccupancy * float64(totalSeats))
-		entry := entities.SeatLogEntry{
-			CinemaName: s.CinemaName,
-			FilmName:   s.FilmName,
-			SessionId:  s.Session.SessionId,
-			Seats:      seatsNum,
-			StartHour:  s.Session.StartHour,
-			LoggedAt:   time.Now(),
+		fmt.Printf("🛑 %d sessions were never sampled, recorded in %s\n", len(unsampled), unsampledFile)
+	}
+	return nil
+}
+
+// sampleSeats counts the seats of s at url and logs them. While the circuit of the seats endpoint is open
+// the sample is deferred, up to Sampling.MaxDefer; it returns false when the deferral is given up or
+// cut short by the shutdown, so that the session is recorded as never sampled.
+func sampleSeats(ctx context.Context, options *SettimersOptions, c client.Extractor, url string, s entities.ScheduledSession) bool {
+	deadline := options.Sampling.Deadline.Duration()
+	// The deadline starts when the deferred sample is sent
+	deferUntil := options.now().Add(options.Sampling.MaxDefer.Duration())
+	var seatResp *entities.Response
+	var late bool
+	var err error
+	var open *client.CircuitOpenError
+	for {
+		seatResp, late, err = callSeats(ctx, c, url, deadline)
+		if !errors.As(err, &open) {
+			break
 		}
-		if err := options.Persistence.WriteSessionSeats(context.Background(), entry); err != nil {
-			fmt.Printf("❌❌ Error logging seat count for session %s: %v\n", s.Session.SessionId, err)
-			fmt.Println("The missing log entry is: ", entry)
+		if open.RetryAt.After(deferUntil) {
+			fmt.Printf("⚠️  Seat count for session %s deferred for more than %s, left unsampled (%v)\n", s.Session.SessionId, options.Sampling.MaxDefer, err)
+			return false
 		}
-		fmt.Println("File correctly written to db!")
-	})
-	return nil
+		fmt.Printf("🔌 Deferring the seat count for session %s to %s: %v\n", s.Session.SessionId, open.RetryAt.Format(time.RFC3339), err)
+		if !options.sleepUntil(ctx, open.RetryAt) {
+			fmt.Printf("⚠️  Seat count for session %s deferred past the shutdown, left unsampled\n", s.Session.SessionId)
+			return false
+		}
+	}
+	if late {
+		fmt.Printf("⚠️  Seat count for session %s not taken within the %s deadline: dropped\n", s.Session.SessionId, deadline)
+		return true
+	}
+	if errors.Is(err, client.ErrNotFound) {
+		fmt.Printf("⚠️  Session %s of %s at %s is gone (cancelled or closed), no seat count\n", s.Session.SessionId, s.FilmName, s.CinemaName)
+		return true
+	}
+	if err != nil {
+		var httpErr *client.HTTPError
+		if errors.As(err, &httpErr) {
+			fmt.Printf("❌❌ Error counting seats for session %s: %s answered %d (%v)\n", s.Session.SessionId, httpErr.URL, httpErr.StatusCode, httpErr.Kind)
+		} else {
+			fmt.Printf("❌❌ Error counting seats for session %s: %v\n", s.Session.SessionId, err)
+		}
+		return true
+	}
+	totalSeats := seatResp.Result.SeatRows.CountSeats()
+	seatsNum := int(seatResp.Result.SessionOccupancy * float64(totalSeats))
+	entry := entities.SeatLogEntry{
+		Site:       options.Site.Name,
+		CinemaName: s.CinemaName,
+		FilmName:   s.FilmName,
+		SessionId:  s.Session.SessionId,
+		Seats:      seatsNum,
+		StartHour:  s.Session.StartHour,
+		LoggedAt:   options.now(),
+	}
+	// The sample has been taken: flush it even if the run is being cancelled
+	writeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), flushTimeout)
+	defer cancel()
+	if err := options.Persistence.WriteSessionSeats(writeCtx, entry); err != nil {
+		fmt.Printf("❌❌ Error logging seat count for session %s: %v\n", s.Session.SessionId, err)
+		fmt.Println("The missing log entry is: ", entry)
+	}
+	fmt.Println("File correctly written to db!")
+	return true
+}
+
+// callSeats calls url within deadline. A call in flight is completed on shutdown, within its deadline;
+// late reports that it failed because the deadline expired.
+func callSeats(ctx context.Context, c client.Extractor, url string, deadline time.Duration) (resp *entities.Response, late bool, err error) {
+	callCtx := context.WithoutCancel(ctx)
+	if deadline > 0 {
+		var cancel context.CancelFunc
+		callCtx, cancel = context.WithTimeout(callCtx, deadline)
+		defer cancel()
+	}
+	resp, err = c.CallSeats(callCtx, url)
+	return resp, err != nil && callCtx.Err() != nil, err
+}
+
+// sleepUntil waits until the clock of the timers reaches t, returning false if ctx is cancelled first
+func (o *SettimersOptions) sleepUntil(ctx context.Context, t time.Time) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-o.delay(t.Sub(o.now())):
+		return true
+	}
+}
+
+// writeUnsampled appends sessions to the JSON array in file, so repeated interruptions of the same day add up
+func writeUnsampled(file string, sessions []entities.ScheduledSession) error {
+	var existing []entities.ScheduledSession
+	if data, err := os.ReadFile(file); err == nil {
+		if err := json.Unmarshal(data, &existing); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+	}
+	data, err := json.MarshalIndent(append(existing, sessions...), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal unsampled sessions: %w", err)
+	}
+	return os.WriteFile(file, data, 0644)
 }
@@ -0,0 +1,220 @@
+package settimers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/paologalligit/go-extractor/cassette"
+	"github.com/paologalligit/go-extractor/client"
+	"github.com/paologalligit/go-extractor/config"
+	"github.com/paologalligit/go-extractor/entities"
+	"github.com/paologalligit/go-extractor/fakeapi"
+	"github.com/paologalligit/go-extractor/header"
+	"github.com/paologalligit/go-extractor/persistence"
+	"github.com/paologalligit/go-extractor/site"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// openCircuit answers the seats calls with an open circuit until retryIn from the call, on the clock now.
+// When closesAfter is set, the calls after the first closesAfter ones get the seats.
+type openCircuit struct {
+	now         func() time.Time
+	retryIn     time.Duration
+	closesAfter int
+	calls       int
+}
+
+func (o *openCircuit) CallShowings(ctx context.Context, url string) (*entities.ShowingResponse, error) {
+	return nil, nil
+}
+
+func (o *openCircuit) CallSeats(ctx context.Context, url string) (*entities.Response, error) {
+	o.calls++
+	if o.closesAfter > 0 && o.calls > o.closesAfter {
+		return &entities.Response{Result: entities.Result{SessionOccupancy: 0.5}}, nil
+	}
+	return nil, &client.CircuitOpenError{Circuit: "seats@test", RetryAt: o.now().Add(o.retryIn)}
+}
+
+func TestSampleSeats_Deferred(t *testing.T) {
+	session := entities.ScheduledSession{CinemaId: "1030", Session: entities.Session{SessionId: "1", StartHour: "21:00"}}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name           string
+		ctx            context.Context
+		retryIn        time.Duration
+		closesAfter    int
+		expectsCalls   int
+		expectsSampled bool
+	}{
+		{
+			name:           "sampled once the circuit lets calls through",
+			ctx:            context.Background(),
+			retryIn:        time.Minute,
+			closesAfter:    1,
+			expectsCalls:   2,
+			expectsSampled: true,
+		},
+		{
+			name:         "deferred for more than the max defer",
+			ctx:          context.Background(),
+			retryIn:      time.Hour,
+			expectsCalls: 1,
+		},
+		{
+			name:         "deferral cut short by the shutdown",
+			ctx:          cancelled,
+			retryIn:      time.Minute,
+			expectsCalls: 1,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// The deferral runs on the clock of the timers, not on the wall clock
+			clock := cassette.NewClock(time.Date(2025, 9, 15, 20, 0, 0, 0, time.UTC), 36000)
+			options := &SettimersOptions{
+				Persistence: persistence.NewFilePersistence(filepath.Join(t.TempDir(), "seats.jsonl")),
+				Sampling:    config.Default().Sampling,
+				Now:         clock.Now,
+				Delay:       clock.After,
+			}
+			extractor := &openCircuit{now: clock.Now, retryIn: tc.retryIn, closesAfter: tc.closesAfter}
+
+			sampled := sampleSeats(tc.ctx, options, extractor, "https://example.com/seats", session)
+
+			assert.Equal(t, tc.expectsSampled, sampled)
+			assert.Equal(t, tc.expectsCalls, extractor.calls)
+		})
+	}
+}
+
+func TestSampleSeats_DeadlineOpensCircuit(t *testing.T) {
+	rome, err := time.LoadLocation("Europe/Rome")
+	require.NoError(t, err)
+	scenario := &fakeapi.Scenario{
+		Cinemas:  []fakeapi.Cinema{{Id: "1030", Name: "Vimercate"}},
+		Films:    []fakeapi.Film{{Id: "F1", Title: "Film A"}},
+		Sessions: []fakeapi.Session{{Id: "1", Cinema: "1030", Film: "F1", Time: "21:00"}},
+		// The seats endpoint answers long after the sample deadline
+		Faults: []fakeapi.Fault{{Kind: fakeapi.FaultSlow, Endpoint: fakeapi.EndpointSeats, Delay: config.Duration(time.Minute)}},
+	}
+	server, err := fakeapi.New(scenario)
+	require.NoError(t, err)
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+	testSite := site.Site{Name: "test", BaseURL: httpServer.URL, Location: rome}
+	sampling := config.Default().Sampling
+	sampling.Deadline = config.Duration(50 * time.Millisecond)
+	options := &SettimersOptions{Site: testSite, Sampling: sampling}
+	c := client.NewWithOptions(header.NoAuthProvider{}, client.Options{Breaker: client.BreakerSettings{Threshold: 2, Cooldown: time.Minute}})
+	session := entities.ScheduledSession{CinemaId: "1030", Session: entities.Session{SessionId: "1", StartHour: "21:00"}}
+	url := testSite.Endpoints().Seats(session.CinemaId, session.Session.SessionId)
+
+	// Each sample is dropped at its deadline, and the timeouts add up to open the circuit
+	for range 2 {
+		assert.True(t, sampleSeats(context.Background(), options, c, url, session), "a late sample is dropped, not left unsampled")
+	}
+
+	circuits := c.Circuits()
+	require.Len(t, circuits, 1)
+	assert.Equal(t, client.CircuitOpen, circuits[0].State)
+	assert.Equal(t, 2, circuits[0].Failures)
+}
+
+func TestWriteUnsampled(t *testing.T) {
+	file := filepath.Join(t.TempDir(), UnsampledFile("thespacecinema", time.Date(2025, 9, 15, 0, 0, 0, 0, time.UTC)))
+	first := []entities.ScheduledSession{{CinemaId: "1030", Session: entities.Session{SessionId: "1"}}}
+	second := []entities.ScheduledSession{{CinemaId: "1018", Session: entities.Session{SessionId: "2"}}}
+
+	require.NoError(t, writeUnsampled(file, first))
+	require.NoError(t, writeUnsampled(file, second))
+
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	var recorded []entities.ScheduledSession
+	require.NoError(t, json.Unmarshal(data, &recorded))
+	assert.Equal(t, append(first, second...), recorded)
+}
+
+func TestRunDay_Replay(t *testing.T) {
+	rome, err := time.LoadLocation("Europe/Rome")
+	require.NoError(t, err)
+	// The day was recorded a while ago: replaying it on the wall clock would find every session started
+	recordedAt := time.Date(2025, 9, 15, 17, 0, 0, 0, rome)
+	const speed = 36000
+	scenario := &fakeapi.Scenario{
+		Start:   recordedAt,
+		Speed:   speed,
+		Cinemas: []fakeapi.Cinema{{Id: "1030", Name: "Vimercate"}},
+		Films:   []fakeapi.Film{{Id: "F1", Title: "Film A"}},
+		Sessions: []fakeapi.Session{
+			{Id: "1", Cinema: "1030", Film: "F1", Time: "18:00", Seats: 100, Occupancy: fakeapi.Occupancy{Open: 0.2, Final: 0.6}},
+			{Id: "2", Cinema: "1030", Film: "F1", Time: "21:15", Seats: 100, Occupancy: fakeapi.Occupancy{Open: 0.1, Final: 0.5}},
+		},
+	}
+	server, err := fakeapi.New(scenario)
+	require.NoError(t, err)
+	httpServer := httptest.NewServer(server)
+	testSite := site.Site{Name: "test", BaseURL: httpServer.URL, Location: rome}
+	sampling := config.Default().Sampling
+	sampling.JitterMax = config.Duration(time.Second)
+	dir := t.TempDir()
+	cassetteDir := filepath.Join(dir, "cassette")
+	// The sessions file is written to the working directory
+	t.Chdir(dir)
+
+	// runDay tracks the recorded day through transport, on a clock starting at start
+	runDay := func(name string, start time.Time, wrap func(http.RoundTripper) http.RoundTripper) []entities.SeatLogEntry {
+		clock := cassette.NewClock(start, speed)
+		p := persistence.NewFilePersistence(filepath.Join(dir, name+".jsonl"))
+		options := &SettimersOptions{
+			Client:        client.NewWithOptions(header.NoAuthProvider{}, client.Options{WrapTransport: wrap}),
+			Persistence:   p,
+			MaxGoroutines: 2,
+			Site:          testSite,
+			FilesPath:     filepath.Join(dir, name),
+			Sampling:      sampling,
+			Now:           clock.Now,
+			Delay:         clock.After,
+		}
+		day := testSite.Today(start)
+		require.NoError(t, RunDay(context.Background(), options, day))
+		require.NoError(t, os.Remove(TodayFile(testSite.Name, day)))
+		var entries []entities.SeatLogEntry
+		require.NoError(t, p.ReadSessionSeats(context.Background(), persistence.SessionFilter{}, func(entry entities.SeatLogEntry) error {
+			entries = append(entries, entry)
+			return nil
+		}))
+		return entries
+	}
+
+	recorder, err := cassette.NewRecorderWithClock(cassetteDir, cassette.NewClock(recordedAt, speed).Now)
+	require.NoError(t, err)
+	recorded := runDay("recorded", recordedAt, recorder.Wrap)
+	require.Len(t, recorded, 2)
+	httpServer.Close()
+
+	// Replay offline, starting from the time the cassette tells
+	replayer, err := cassette.NewReplayer(cassetteDir)
+	require.NoError(t, err)
+	assert.Equal(t, "2025-09-15", testSite.Today(replayer.RecordedAt()).Format("2006-01-02"))
+	replayed := runDay("replayed", replayer.RecordedAt(), replayer.Wrap)
+
+	require.Len(t, replayed, len(recorded))
+	for i := range recorded {
+		assert.Equal(t, recorded[i].SessionId, replayed[i].SessionId)
+		assert.Equal(t, recorded[i].Seats, replayed[i].Seats)
+		assert.Equal(t, "2025-09-15", replayed[i].LoggedAt.In(rome).Format("2006-01-02"), "sampled on the recorded day")
+	}
+	_, err = os.Stat(UnsampledFile(testSite.Name, testSite.Today(recordedAt)))
+	assert.True(t, os.IsNotExist(err), "every session is sampled")
+}
@@ -0,0 +1,42 @@
+package site
+
+import (
+	"time"
+	// Sites run in their own time zone, whatever the zoneinfo of the host
+	_ "time/tzdata"
+
+	"github.com/paologalligit/go-extractor/api"
+)
+
+// DefaultName tags the records of the site configured with api.base_url, and the records stored
+// before sites were introduced
+const DefaultName = "thespacecinema"
+
+// Site is one cinema chain website running on the same platform, like thespacecinema.it
+// and its sibling chains in other countries
+type Site struct {
+	// Name tags every record persisted for the site
+	Name    string
+	BaseURL string
+	// Locale is sent as Accept-Language, like it-IT
+	Locale string
+	// Location is the time zone of the showing times
+	Location *time.Location
+	// Currency is the ISO 4217 code of the prices
+	Currency string
+	// AuthURL is the page the browser opens to collect the cookies
+	AuthURL string
+}
+
+// Endpoints returns the API endpoints of the site
+func (s Site) Endpoints() *api.Endpoints {
+	return api.New(s.BaseURL)
+}
+
+// Today returns the showing date of the site at now
+func (s Site) Today(now time.Time) time.Time {
+	if s.Location == nil {
+		return now
+	}
+	return now.In(s.Location)
+}
@@ -1,6 +1,7 @@
 package team
 
 import (
+	"context"
 	"sync"
 )
 
@@ -16,8 +17,9 @@ type Task[T any, U any] struct {
 	Outcome      Outcome[U]
 }
 
-// WorkerFunc is a function that processes a job of type T and returns a result of type U (and optionally error)
-type WorkerFunc[T any, U any] func(T) (U, error)
+// WorkerFunc is a function that processes a job of type T and returns a result of type U (and optionally error).
+// It receives the ctx of Run and must return once it is cancelled.
+type WorkerFunc[T any, U any] func(context.Context, T) (U, error)
 
 // Team is a generic worker pool
 // WorkerCount: number of concurrent workers
@@ -27,8 +29,9 @@ type Team[T any, U any] struct {
 	Worker      WorkerFunc[T, U]
 }
 
-// Run executes the worker pool: feeds jobs, collects results, returns result slice
-func (t *Team[T, U]) Run(jobs []T) []U {
+// Run executes the worker pool: feeds jobs, collects results, returns result slice.
+// Once ctx is cancelled the remaining jobs are dropped.
+func (t *Team[T, U]) Run(ctx context.Context, jobs []T) []U {
 	jobChan := make(chan T, len(jobs))
 	resultChan := make(chan U, len(jobs))
 	var wg sync.WaitGroup
@@ -39,7 +42,10 @@ func (t *Team[T, U]) Run(jobs []T) []U {
 		go func() {
 			defer wg.Done()
 			for job := range jobChan {
-				if res, err := t.Worker(job); err == nil {
+				if ctx.Err() != nil {
+					continue
+				}
+				if res, err := t.Worker(ctx, job); err == nil {
 					resultChan <- res
 				}
 			}
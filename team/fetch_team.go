@@ -1,24 +1,26 @@
 package team
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
 	"sync/atomic"
-	"time"
 
+	"github.com/paologalligit/go-extractor/api"
 	"github.com/paologalligit/go-extractor/client"
-	"github.com/paologalligit/go-extractor/constant"
 	"github.com/paologalligit/go-extractor/entities"
 	"github.com/paologalligit/go-extractor/utils"
 )
 
 type FetchTeamWorkingMaterial struct {
-	RequestDelay int
-	RegionData   []entities.Region
-	ShowingUrl   string
-	Completed    *int64
-	Client       client.Extractor
+	RegionData []entities.Region
+	Endpoints  *api.Endpoints
+	Site       string // Tags the fetched showings
+	Completed  *int64
+	// Client paces the requests with its rate limiter
+	Client client.Extractor
 }
 
 type FetchTeam struct {
@@ -33,12 +35,12 @@ func NewFetchTeam(workerCount int, wm *FetchTeamWorkingMaterial) *FetchTeam {
 	}
 }
 
-func (ft *FetchTeam) Run(workItems []entities.WorkItem) []entities.ShowingResult {
+func (ft *FetchTeam) Run(ctx context.Context, workItems []entities.WorkItem) []entities.ShowingResult {
 	// Stage 1: Fetch showings for each work item
 	showingTeam := Team[entities.WorkItem, entities.ShowingResult]{
 		WorkerCount: ft.WorkerCount,
-		Worker: func(job entities.WorkItem) (entities.ShowingResult, error) {
-			result, err := ft.fetchShowing(job.CinemaId, job.FilmId, ft.WorkingMaterial.RegionData, ft.WorkingMaterial.ShowingUrl)
+		Worker: func(ctx context.Context, job entities.WorkItem) (entities.ShowingResult, error) {
+			result, err := ft.fetchShowing(ctx, job.CinemaId, job.FilmId, ft.WorkingMaterial.RegionData)
 			if err != nil {
 				return entities.ShowingResult{}, fmt.Errorf("error fetching showing for cinema %s, film %s: %w", job.CinemaId, job.FilmId, err)
 			}
@@ -48,7 +50,7 @@ func (ft *FetchTeam) Run(workItems []entities.WorkItem) []entities.ShowingResult
 			return result, nil
 		},
 	}
-	showingResults := showingTeam.Run(workItems)
+	showingResults := showingTeam.Run(ctx, workItems)
 
 	var filteredShowings []entities.ShowingResult
 	for _, result := range showingResults {
@@ -60,8 +62,8 @@ func (ft *FetchTeam) Run(workItems []entities.WorkItem) []entities.ShowingResult
 	// Stage 2: For each showing, fetch all seats and aggregate
 	seatsTeam := Team[entities.ShowingResult, entities.ShowingResult]{
 		WorkerCount: ft.WorkerCount,
-		Worker: func(showing entities.ShowingResult) (entities.ShowingResult, error) {
-			booking, err := ft.fetchAllSeats(showing.CinemaId, &showing)
+		Worker: func(ctx context.Context, showing entities.ShowingResult) (entities.ShowingResult, error) {
+			booking, err := ft.fetchAllSeats(ctx, showing.CinemaId, &showing)
 			if err != nil {
 				return entities.ShowingResult{}, fmt.Errorf("error fetching booking for cinema %s, film %s: %w", showing.CinemaId, showing.FilmId, err)
 			}
@@ -69,17 +71,19 @@ func (ft *FetchTeam) Run(workItems []entities.WorkItem) []entities.ShowingResult
 			if ft.WorkingMaterial.Completed != nil {
 				atomic.AddInt64(ft.WorkingMaterial.Completed, 1)
 			}
-			time.Sleep(time.Duration(ft.WorkingMaterial.RequestDelay) * time.Millisecond)
 			return showing, nil
 		},
 	}
-	finalResults := seatsTeam.Run(filteredShowings)
+	finalResults := seatsTeam.Run(ctx, filteredShowings)
 	return finalResults
 }
 
-func (ft *FetchTeam) fetchShowing(cinemaId string, filmId string, regionData []entities.Region, showingUrl string) (entities.ShowingResult, error) {
-	url := fmt.Sprintf(showingUrl, cinemaId, filmId)
-	showingResp, err := ft.WorkingMaterial.Client.CallShowings(url)
+func (ft *FetchTeam) fetchShowing(ctx context.Context, cinemaId string, filmId string, regionData []entities.Region) (entities.ShowingResult, error) {
+	showingResp, err := ft.WorkingMaterial.Client.CallShowings(ctx, ft.WorkingMaterial.Endpoints.Showings(cinemaId, filmId))
+	if errors.Is(err, client.ErrNotFound) {
+		// The film is not scheduled at this cinema
+		return entities.ShowingResult{}, nil
+	}
 	if err != nil {
 		return entities.ShowingResult{}, err
 	}
@@ -90,6 +94,7 @@ func (ft *FetchTeam) fetchShowing(cinemaId string, filmId string, regionData []e
 		return entities.ShowingResult{}, nil
 	}
 	result := entities.ShowingResult{
+		Site:          ft.WorkingMaterial.Site,
 		Movie:         showingResp.Result[0].FilmTitle,
 		FilmId:        showingResp.Result[0].FilmId,
 		CinemaId:      cinemaId,
@@ -99,11 +104,13 @@ func (ft *FetchTeam) fetchShowing(cinemaId string, filmId string, regionData []e
 	return result, nil
 }
 
-func (ft *FetchTeam) fetchAllSeats(cinemaId string, showingResult *entities.ShowingResult) (map[string]*entities.Response, error) {
+func (ft *FetchTeam) fetchAllSeats(ctx context.Context, cinemaId string, showingResult *entities.ShowingResult) (map[string]*entities.Response, error) {
 	var wg sync.WaitGroup
 	var mutex sync.Mutex
 	results := make(map[string]*entities.Response)
 	errChan := make(chan error, 1)
+	// Bound the requests in flight per showing, the rate limiter paces them
+	slots := make(chan struct{}, max(ft.WorkerCount, 1))
 
 	for _, group := range showingResult.ShowingGroups {
 		for _, session := range group.Sessions {
@@ -111,8 +118,14 @@ func (ft *FetchTeam) fetchAllSeats(cinemaId string, showingResult *entities.Show
 			wg.Add(1)
 			go func(sessionId string) {
 				defer wg.Done()
-				url := fmt.Sprintf(constant.SEATS_URL, cinemaId, sessionId)
-				seatResponse, err := ft.WorkingMaterial.Client.CallSeats(url)
+				slots <- struct{}{}
+				defer func() { <-slots }()
+				seatResponse, err := ft.WorkingMaterial.Client.CallSeats(ctx, ft.WorkingMaterial.Endpoints.Seats(cinemaId, sessionId))
+				if errors.Is(err, client.ErrNotFound) {
+					// The session was cancelled since the showings were listed: keep the others
+					fmt.Printf("⚠️  Session %s of cinema %s not found, skipping its seats\n", sessionId, cinemaId)
+					return
+				}
 				if err != nil {
 					select {
 					case errChan <- fmt.Errorf("error making request for session %s: %v", sessionId, err):
@@ -138,9 +151,11 @@ func (ft *FetchTeam) fetchAllSeats(cinemaId string, showingResult *entities.Show
 func aggregateBookingWithResult(result *entities.ShowingResult, booking map[string]*entities.Response) {
 	for _, group := range result.ShowingGroups {
 		for i := range group.Sessions {
-			seatsNum := booking[group.Sessions[i].SessionId].Result.SeatRows.CountSeats()
-			group.Sessions[i].Seats = int(booking[group.Sessions[i].SessionId].Result.SessionOccupancy * float64(seatsNum))
-			group.Sessions[i].TotalSeats = seatsNum
+			if seats, ok := booking[group.Sessions[i].SessionId]; ok && seats != nil {
+				seatsNum := seats.Result.SeatRows.CountSeats()
+				group.Sessions[i].Seats = int(seats.Result.SessionOccupancy * float64(seatsNum))
+				group.Sessions[i].TotalSeats = seatsNum
+			}
 
 			// Extract hour and minute from StartTime (format: 'YYYY-MM-DDTHH:MM:SS')
 			if group.Sessions[i].StartTime != "" {
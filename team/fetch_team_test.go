@@ -1,27 +1,60 @@
 package team
 
 import (
-	"encoding/json"
-	"os"
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
-	"github.com/paologalligit/go-extractor/constant"
+	"github.com/paologalligit/go-extractor/api"
+	"github.com/paologalligit/go-extractor/client"
+	"github.com/paologalligit/go-extractor/config"
 	"github.com/paologalligit/go-extractor/entities"
+	"github.com/paologalligit/go-extractor/fakeapi"
+	"github.com/paologalligit/go-extractor/header"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
-const (
-	FILE_PATH_SHOWINGS_TEST = "/Users/paologalli/Documents/go_extractor/team/call_showings_template.json"
-	FILE_PATH_SEATS_TEST    = "/Users/paologalli/Documents/go_extractor/team/seats_template.json"
-)
+// newFakeAPI serves scenario over HTTP and returns a client and the endpoints calling it
+func newFakeAPI(t *testing.T, scenario *fakeapi.Scenario) (*client.ExtractorClient, *api.Endpoints) {
+	t.Helper()
+	server, err := fakeapi.New(scenario)
+	require.NoError(t, err)
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+	return client.NewWithOptions(header.NoAuthProvider{}, client.Options{}), api.New(httpServer.URL)
+}
+
+// weekScenario shows one film at one cinema every evening of the next 8 days
+func weekScenario() *fakeapi.Scenario {
+	scenario := &fakeapi.Scenario{
+		Cinemas: []fakeapi.Cinema{{Id: "1030", Name: "Vimercate"}},
+		Films:   []fakeapi.Film{{Id: "HO00003077", Title: "The Conjuring: Il rito finale"}},
+	}
+	for day := 1; day <= 8; day++ {
+		for _, start := range []string{"18:30", "21:15"} {
+			scenario.Sessions = append(scenario.Sessions, fakeapi.Session{
+				Id:        fmt.Sprintf("%d-%s", day, start),
+				Cinema:    "1030",
+				Film:      "HO00003077",
+				Day:       day,
+				Time:      start,
+				Seats:     120,
+				Occupancy: fakeapi.Occupancy{Open: 0.25, Final: 0.9},
+			})
+		}
+	}
+	return scenario
+}
 
 func TestFetchTeam(t *testing.T) {
 	// Arrange
-	extractor := &MockFetchExtractor{}
+	extractor, endpoints := newFakeAPI(t, weekScenario())
 	ftwm := &FetchTeamWorkingMaterial{
-		Client:       extractor,
-		ShowingUrl:   constant.SHOWINGS_URL,
-		RequestDelay: 100,
+		Client:    extractor,
+		Endpoints: endpoints,
 		RegionData: []entities.Region{
 			{
 				Cinemas: []entities.Cinema{
@@ -36,7 +69,7 @@ func TestFetchTeam(t *testing.T) {
 
 	// Act
 	ft := NewFetchTeam(1, ftwm)
-	result := ft.Run([]entities.WorkItem{{CinemaId: "1030", FilmId: "HO00003077"}})
+	result := ft.Run(context.Background(), []entities.WorkItem{{CinemaId: "1030", FilmId: "HO00003077"}})
 
 	// Assert
 	assert.NotEmpty(t, result)
@@ -61,28 +94,52 @@ func TestFetchTeam(t *testing.T) {
 	}
 }
 
-type MockFetchExtractor struct{}
+// notFoundExtractor answers 404 for the sessions in gone and serves one occupied seat otherwise
+type notFoundExtractor struct {
+	gone map[string]bool
+}
 
-func (m *MockFetchExtractor) CallShowings(url string) (*entities.ShowingResponse, error) {
-	data, err := os.ReadFile(FILE_PATH_SHOWINGS_TEST)
-	if err != nil {
-		return nil, err
-	}
-	var resp entities.ShowingResponse
-	if err := json.Unmarshal(data, &resp); err != nil {
-		return nil, err
-	}
-	return &resp, nil
+func (m *notFoundExtractor) CallShowings(ctx context.Context, url string) (*entities.ShowingResponse, error) {
+	return nil, &client.HTTPError{Kind: client.ErrNotFound, URL: url, StatusCode: 404}
 }
 
-func (m *MockFetchExtractor) CallSeats(url string) (*entities.Response, error) {
-	data, err := os.ReadFile(FILE_PATH_SEATS_TEST)
-	if err != nil {
-		return nil, err
+func (m *notFoundExtractor) CallSeats(ctx context.Context, url string) (*entities.Response, error) {
+	for sessionId := range m.gone {
+		if strings.HasSuffix(url, "/"+sessionId+"/seats") {
+			return nil, &client.HTTPError{Kind: client.ErrNotFound, URL: url, StatusCode: 404}
+		}
 	}
 	var resp entities.Response
-	if err := json.Unmarshal(data, &resp); err != nil {
-		return nil, err
-	}
+	resp.Result.SessionOccupancy = 1
+	resp.Result.SeatRows = entities.Platea{{Columns: []*entities.Seat{{}}}}
 	return &resp, nil
 }
+
+func TestFetchTeam_NotFound(t *testing.T) {
+	ft := NewFetchTeam(2, &FetchTeamWorkingMaterial{
+		Endpoints: api.New(config.Default().API.BaseURL),
+		Client:    &notFoundExtractor{gone: map[string]bool{"cancelled": true}},
+	})
+
+	// A film missing at a cinema is no showing, not an error
+	result, err := ft.fetchShowing(context.Background(), "1018", "HO00001", nil)
+	assert.NoError(t, err)
+	assert.Empty(t, result.FilmId)
+
+	// A cancelled session is skipped, the others keep their seats
+	showing := entities.ShowingResult{
+		CinemaId: "1018",
+		ShowingGroups: []entities.ShowingGroup{{Sessions: []entities.Session{
+			{SessionId: "cancelled", StartTime: "2025-09-19T20:30:00"},
+			{SessionId: "open", StartTime: "2025-09-19T22:30:00"},
+		}}},
+	}
+	booking, err := ft.fetchAllSeats(context.Background(), "1018", &showing)
+	assert.NoError(t, err)
+	aggregateBookingWithResult(&showing, booking)
+	sessions := showing.ShowingGroups[0].Sessions
+	assert.Equal(t, 0, sessions[0].TotalSeats)
+	assert.Equal(t, "20:30", sessions[0].StartHour)
+	assert.Equal(t, 1, sessions[1].TotalSeats)
+	assert.Equal(t, 1, sessions[1].Seats)
+}
@@ -1,7 +1,9 @@
 package team
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math/rand"
 	"os"
@@ -9,8 +11,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/paologalligit/go-extractor/api"
 	"github.com/paologalligit/go-extractor/client"
-	"github.com/paologalligit/go-extractor/constant"
+	"github.com/paologalligit/go-extractor/config"
 	"github.com/paologalligit/go-extractor/entities"
 	"github.com/paologalligit/go-extractor/utils"
 )
@@ -18,13 +21,17 @@ import (
 type DelayFunc func(time.Duration) <-chan time.Time
 
 type SessionTeamWorkingMaterial struct {
-	RequestDelay  int
 	Completed     *int64
 	Client        client.Extractor
 	MaxGoroutines int
 	CinemaIds     []string
 	RegionData    []entities.Region
-	Delay         DelayFunc // Injected delay function for timers
+	Endpoints     *api.Endpoints
+	Site          string         // Tags the fetched showings
+	Location      *time.Location // Time zone of the start hours, the one of now when nil
+	Sampling      config.Sampling
+	Delay         DelayFunc        // Injected delay function for timers
+	Now           func() time.Time // Injected clock the timers are scheduled on, the wall clock when nil
 }
 
 type SessionTeam struct {
@@ -39,30 +46,63 @@ func NewSessionTeam(workerCount int, wm *SessionTeamWorkingMaterial) *SessionTea
 	}
 }
 
-// Pipeline: For each ScheduledSession, schedule a timer, fetch seat data, and aggregate
-func (st *SessionTeam) Run(today, todayFile string, callback func(s entities.ScheduledSession)) ([]entities.ScheduledSession, error) {
+// SessionCallback is called with the session when its timer fires. It returns false when the seats
+// were not sampled but could be later, e.g. a sample deferred past the shutdown.
+type SessionCallback func(ctx context.Context, s entities.ScheduledSession) bool
+
+// Pipeline: For each ScheduledSession, schedule a timer, fetch seat data, and aggregate.
+// Run blocks until every timer has fired or ctx is cancelled, and returns today's sessions
+// along with the ones whose timer was cancelled before firing or whose callback returned false.
+func (st *SessionTeam) Run(ctx context.Context, today, todayFile string, callback SessionCallback) ([]entities.ScheduledSession, []entities.ScheduledSession, error) {
 	// TODO: do we really need to save the today file to disk?
-	if err := st.upsertTodayFile(today, todayFile); err != nil {
-		return nil, fmt.Errorf("error upserting today file: %w", err)
+	if err := st.upsertTodayFile(ctx, today, todayFile); err != nil {
+		return nil, nil, fmt.Errorf("error upserting today file: %w", err)
 	}
 
 	todaySessions, err := st.readTodaySessions(todayFile)
 	if err != nil {
-		return nil, fmt.Errorf("error reading today's sessions: %w", err)
+		return nil, nil, fmt.Errorf("error reading today's sessions: %w", err)
+	}
+
+	unsampled := st.scheduleSessionTimers(ctx, today, todaySessions, callback)
+	return todaySessions, unsampled, nil
+}
+
+// Plan builds the sampling schedule of today's sessions without sampling any seat.
+// It reads todayFile if present, otherwise it fetches the showings without their seat counts
+// and leaves todayFile untouched.
+func (st *SessionTeam) Plan(ctx context.Context, today, todayFile string, now time.Time) ([]entities.PlannedSession, error) {
+	data, err := os.ReadFile(todayFile)
+	if os.IsNotExist(err) {
+		fmt.Printf("%s not found, fetching showings for today without seats...\n", todayFile)
+		data, err = st.fetchTodayShowings(ctx, today, true)
+		if err == nil {
+			err = ctx.Err()
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error loading today's showings: %w", err)
 	}
 
-	st.scheduleSessionTimers(todaySessions, callback)
-	return todaySessions, nil
+	todaySessions, err := st.parseTodaySessions(data)
+	if err != nil {
+		return nil, fmt.Errorf("error reading today's sessions: %w", err)
+	}
+	return st.PlanSessions(today, todaySessions, now), nil
 }
 
 // upsertTodayFile checks for the today file and fetches showings if missing
-func (st *SessionTeam) upsertTodayFile(today, todayFile string) error {
+func (st *SessionTeam) upsertTodayFile(ctx context.Context, today, todayFile string) error {
 	if _, err := os.Stat(todayFile); os.IsNotExist(err) {
 		fmt.Printf("%s not found, fetching showings for today...\n", todayFile)
-		data, err := st.fetchTodayShowings(today)
+		data, err := st.fetchTodayShowings(ctx, today, false)
 		if err != nil {
 			return fmt.Errorf("error fetching today's showings: %w", err)
 		}
+		// Do not leave a partial file behind, the next run would take it as complete
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("fetching today's showings interrupted: %w", err)
+		}
 
 		if err := os.WriteFile(todayFile, data, 0644); err != nil {
 			return fmt.Errorf("failed to write results to file: %w", err)
@@ -74,8 +114,13 @@ func (st *SessionTeam) upsertTodayFile(today, todayFile string) error {
 	return nil
 }
 
-// fetchTodayShowings fetches and writes today's showings to file
-func (st *SessionTeam) fetchTodayShowings(today string) ([]byte, error) {
+// fetchTodayShowings fetches today's showings with the seat count of each session,
+// or without calling the seats endpoint when skipSeats is set
+func (st *SessionTeam) fetchTodayShowings(ctx context.Context, today string, skipSeats bool) ([]byte, error) {
+	date, err := time.Parse("2006-01-02", today)
+	if err != nil {
+		return nil, fmt.Errorf("invalid showing date %q: %w", today, err)
+	}
 	totalRequests := len(st.WorkingMaterial.CinemaIds)
 	workerCount := st.WorkingMaterial.MaxGoroutines
 	if workerCount <= 0 || workerCount > totalRequests {
@@ -84,9 +129,12 @@ func (st *SessionTeam) fetchTodayShowings(today string) ([]byte, error) {
 
 	teamPool := Team[string, []entities.ShowingResult]{
 		WorkerCount: workerCount,
-		Worker: func(item string) ([]entities.ShowingResult, error) {
-			url := fmt.Sprintf(constant.SHOWINGS_URL_TODAY+today+constant.SHOWINGS_URL_TODAY_PARAMS, item)
-			showingResp, err := st.WorkingMaterial.Client.CallShowings(url)
+		Worker: func(ctx context.Context, item string) ([]entities.ShowingResult, error) {
+			showingResp, err := st.WorkingMaterial.Client.CallShowings(ctx, st.WorkingMaterial.Endpoints.ShowingsForDate(item, date))
+			if errors.Is(err, client.ErrNotFound) {
+				// No showings at this cinema today
+				return nil, nil
+			}
 			if err != nil {
 				return nil, err
 			}
@@ -96,6 +144,7 @@ func (st *SessionTeam) fetchTodayShowings(today string) ([]byte, error) {
 			var results []entities.ShowingResult
 			for _, result := range showingResp.Result {
 				showing := entities.ShowingResult{
+					Site:          st.WorkingMaterial.Site,
 					Movie:         result.FilmTitle,
 					FilmId:        result.FilmId,
 					CinemaId:      item,
@@ -106,13 +155,18 @@ func (st *SessionTeam) fetchTodayShowings(today string) ([]byte, error) {
 				for gi := range showing.ShowingGroups {
 					for si := range showing.ShowingGroups[gi].Sessions {
 						session := &showing.ShowingGroups[gi].Sessions[si]
-						seatUrl := fmt.Sprintf(constant.SEATS_URL, item, session.SessionId)
-						seatResp, err := st.WorkingMaterial.Client.CallSeats(seatUrl)
-						if err == nil && seatResp != nil {
-							totalSeats := seatResp.Result.SeatRows.CountSeats()
-							session.TotalSeats = totalSeats
-							seatsNum := int(seatResp.Result.SessionOccupancy * float64(totalSeats))
-							session.Seats = seatsNum
+						if !skipSeats {
+							seatResp, err := st.WorkingMaterial.Client.CallSeats(ctx, st.WorkingMaterial.Endpoints.Seats(item, session.SessionId))
+							// A session missing from the seat map is still scheduled: its timer will tell
+							if err != nil && !errors.Is(err, client.ErrNotFound) {
+								fmt.Printf("❌ Error fetching seats for session %s: %v\n", session.SessionId, err)
+							}
+							if err == nil && seatResp != nil {
+								totalSeats := seatResp.Result.SeatRows.CountSeats()
+								session.TotalSeats = totalSeats
+								seatsNum := int(seatResp.Result.SessionOccupancy * float64(totalSeats))
+								session.Seats = seatsNum
+							}
 						}
 						// Set StartHour and RoundedStartHour from StartTime
 						if session.StartTime != "" {
@@ -136,7 +190,7 @@ func (st *SessionTeam) fetchTodayShowings(today string) ([]byte, error) {
 		},
 	}
 	var allResults []entities.ShowingResult
-	for _, results := range teamPool.Run(st.WorkingMaterial.CinemaIds) {
+	for _, results := range teamPool.Run(ctx, st.WorkingMaterial.CinemaIds) {
 		allResults = append(allResults, results...)
 	}
 	return json.MarshalIndent(allResults, "", "  ")
@@ -147,9 +201,17 @@ func (st *SessionTeam) readTodaySessions(todayFile string) ([]entities.Scheduled
 	if err != nil {
 		return nil, fmt.Errorf("failed to read %s: %w", todayFile, err)
 	}
+	sessions, err := st.parseTodaySessions(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %s: %w", todayFile, err)
+	}
+	return sessions, nil
+}
+
+func (st *SessionTeam) parseTodaySessions(data []byte) ([]entities.ScheduledSession, error) {
 	var showingResults []entities.ShowingResult
 	if err := json.Unmarshal(data, &showingResults); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal %s: %w", todayFile, err)
+		return nil, err
 	}
 
 	for _, showingResult := range showingResults {
@@ -178,52 +240,106 @@ func (st *SessionTeam) convertToScheduledSessions(showingResults []entities.Show
 }
 
 // scheduleSessionTimers schedules a timer for each session and calls the provided callback when the timer fires.
-func (st *SessionTeam) scheduleSessionTimers(sessions []entities.ScheduledSession, callback func(s entities.ScheduledSession)) {
+// Start hours are resolved against the showing date today, so the schedule does not depend on when it is built.
+// When ctx is cancelled the pending timers are dropped and the callbacks already running are waited for.
+// The sessions that were never sampled are returned: the dropped ones and the ones whose callback returned false.
+func (st *SessionTeam) scheduleSessionTimers(ctx context.Context, today string, sessions []entities.ScheduledSession, callback SessionCallback) []entities.ScheduledSession {
 	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	var unsampled []entities.ScheduledSession
 	delayFunc := st.WorkingMaterial.Delay
 	if delayFunc == nil {
 		delayFunc = time.After
 	}
-	for _, session := range sessions {
-		loc := time.Now().Location()
-		now := time.Now()
-		startTimeStr := now.Format("2006-01-02") + "T" + session.Session.StartHour + ":00"
-		startTime, err := time.ParseInLocation("2006-01-02T15:04:05", startTimeStr, loc)
-		if err == nil && startTime.Before(now) {
-			hour := startTime.Hour()
-			// Only add 24 hours if the hour is between midnight and 6 a.m.
-			if hour >= 0 && hour < 6 {
-				startTime = startTime.Add(24 * time.Hour)
+	now := st.WorkingMaterial.Now
+	if now == nil {
+		now = time.Now
+	}
+	for _, plan := range st.PlanSessions(today, sessions, now()) {
+		session := plan.ScheduledSession
+		if plan.Skipped {
+			switch plan.Reason {
+			case entities.PlanReasonUnparseable:
+				fmt.Printf("Failed to parse start time for session %s: %q\n", session.Session.SessionId, session.Session.StartHour)
+			default:
+				fmt.Printf("Session %s already started + 15min, skipping timer\n", session.Session.SessionId)
 			}
-		}
-		if err != nil {
-			fmt.Printf("Failed to parse start time for session %s: %v\n", session.Session.SessionId, err)
-			continue
-		}
-		targetTime := startTime.Add(12 * time.Minute)
-		if session.CinemaId == "1018" {
-			targetTime = startTime.Add(-2 * time.Minute)
-		}
-		minDelay := 100 * time.Millisecond
-		maxDelay := 2 * time.Minute
-		deltaMillis := rand.Int63n(maxDelay.Milliseconds()-minDelay.Milliseconds()+1) + minDelay.Milliseconds()
-		deltaDelay := time.Duration(deltaMillis) * time.Millisecond
-		targetTime = targetTime.Add(deltaDelay)
-		duration := time.Until(targetTime)
-		if duration <= 0 {
-			fmt.Printf("Session %s already started + 15min, skipping timer\n", session.Session.SessionId)
 			continue
 		}
-		fmt.Printf("Scheduling timer for session %s with random delay %v (fires at %s)\n", session.Session.SessionId, deltaDelay, targetTime.Format(time.RFC3339))
+		duration := plan.FireAt.Sub(now())
+		fmt.Printf("Scheduling timer for session %s with random delay %v (fires at %s)\n", session.Session.SessionId, plan.Jitter, plan.FireAt.Format(time.RFC3339))
 		wg.Add(1)
 		go func(s entities.ScheduledSession, delay time.Duration) {
 			defer wg.Done()
-			<-delayFunc(delay)
-			fmt.Printf("Timer expired for session %s at %s, executing callback...\n", s.Session.SessionId, time.Now().Format(time.RFC3339))
-			callback(s)
+			select {
+			case <-delayFunc(delay):
+			case <-ctx.Done():
+				mutex.Lock()
+				unsampled = append(unsampled, s)
+				mutex.Unlock()
+				return
+			}
+			fmt.Printf("Timer expired for session %s at %s, executing callback...\n", s.Session.SessionId, now().Format(time.RFC3339))
+			if !callback(ctx, s) {
+				mutex.Lock()
+				unsampled = append(unsampled, s)
+				mutex.Unlock()
+			}
 		}(session, duration)
 	}
 	wg.Wait()
+	return unsampled
+}
+
+// PlanSessions computes when the seats of each session of the showing date today are sampled, as seen at now.
+// The jitter is drawn at random, so each call gives slightly different fire times.
+func (st *SessionTeam) PlanSessions(today string, sessions []entities.ScheduledSession, now time.Time) []entities.PlannedSession {
+	sampling := st.WorkingMaterial.Sampling
+	loc := st.WorkingMaterial.Location
+	if loc == nil {
+		loc = now.Location()
+	}
+	plans := make([]entities.PlannedSession, 0, len(sessions))
+	for _, session := range sessions {
+		plan := entities.PlannedSession{ScheduledSession: session}
+		startTime, err := SessionStartTime(today, session.Session.StartHour, sampling.RolloverHour, loc)
+		if err != nil {
+			plan.Skipped = true
+			plan.Reason = entities.PlanReasonUnparseable
+			plans = append(plans, plan)
+			continue
+		}
+		plan.StartAt = startTime
+		if startTime.Format("2006-01-02") != today {
+			plan.Reason = entities.PlanReasonRollover
+		}
+
+		targetTime := startTime.Add(sampling.OffsetFor(st.WorkingMaterial.Site, session.CinemaId))
+		minDelay := sampling.JitterMin.Duration()
+		maxDelay := sampling.JitterMax.Duration()
+		deltaMillis := rand.Int63n(maxDelay.Milliseconds()-minDelay.Milliseconds()+1) + minDelay.Milliseconds()
+		plan.Jitter = time.Duration(deltaMillis) * time.Millisecond
+		plan.FireAt = targetTime.Add(plan.Jitter)
+		if !plan.FireAt.After(now) {
+			plan.Skipped = true
+			plan.Reason = entities.PlanReasonStarted
+		}
+		plans = append(plans, plan)
+	}
+	return plans
+}
+
+// SessionStartTime resolves a session's "HH:MM" start hour on the showing date day ("2006-01-02").
+// Sessions starting before rolloverHour run in the night after the showing date, so they are moved to the next day.
+func SessionStartTime(day, startHour string, rolloverHour int, loc *time.Location) (time.Time, error) {
+	startTime, err := time.ParseInLocation("2006-01-02T15:04:05", day+"T"+startHour+":00", loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if startTime.Hour() < rolloverHour {
+		startTime = startTime.AddDate(0, 0, 1)
+	}
+	return startTime, nil
 }
 
 func aggregateWithHour(result *entities.ShowingResult) {
@@ -1,25 +1,26 @@
 package team
 
 import (
-	"encoding/json"
-	"os"
+	"context"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/paologalligit/go-extractor/config"
 	"github.com/paologalligit/go-extractor/entities"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
-const FILE_PATH_SESSIONS_TEST = "/Users/paologalli/Documents/go_extractor/team/today_sessions.json"
-
 var writingMutex sync.Mutex
 
 func TestSessionTeam_Run(t *testing.T) {
 	// Arrange
+	extractor, endpoints := newFakeAPI(t, weekScenario())
 	wm := &SessionTeamWorkingMaterial{
-		RequestDelay:  10,
-		Client:        &MockFetchExtractor{},
+		Client:        extractor,
+		Endpoints:     endpoints,
 		MaxGoroutines: 2,
 		Delay: func(d time.Duration) <-chan time.Time {
 			ch := make(chan time.Time, 1)
@@ -27,6 +28,7 @@ func TestSessionTeam_Run(t *testing.T) {
 			return ch
 		},
 		CinemaIds: []string{"1030"},
+		Sampling:  config.Default().Sampling,
 		RegionData: []entities.Region{
 			{
 				Cinemas: []entities.Cinema{
@@ -37,50 +39,193 @@ func TestSessionTeam_Run(t *testing.T) {
 	}
 	st := NewSessionTeam(2, wm)
 
-	today := "2025-09-15"
-	todayFile := "./todaySession-2025-09-15.json"
-	defer func() {
-		os.Remove(todayFile)
-	}()
+	// Tomorrow's sessions are all still to be sampled, whenever the test runs
+	rome, err := time.LoadLocation("Europe/Rome")
+	require.NoError(t, err)
+	today := time.Now().In(rome).AddDate(0, 0, 1).Format("2006-01-02")
+	todayFile := filepath.Join(t.TempDir(), "todaySession-"+today+".json")
 
 	var called []entities.ScheduledSession
-	callback := func(s entities.ScheduledSession) {
+	callback := func(ctx context.Context, s entities.ScheduledSession) bool {
 		writingMutex.Lock()
 		called = append(called, s)
 		writingMutex.Unlock()
+		return true
 	}
 
 	// Act
-	sessions, err := st.Run(today, todayFile, callback)
+	sessions, _, err := st.Run(context.Background(), today, todayFile, callback)
 
 	// Assert
 	assert.NoError(t, err)
-	assert.NotEmpty(t, sessions)
+	assert.Len(t, sessions, 2)
 	assert.Equal(t, len(sessions), len(called))
+	for _, s := range sessions {
+		assert.Equal(t, 120, s.Session.TotalSeats)
+		assert.Equal(t, 30, s.Session.Seats)
+	}
 }
 
-type MockSessionExtractor struct{}
+func TestSessionTeam_PlanThenRun(t *testing.T) {
+	extractor, endpoints := newFakeAPI(t, weekScenario())
+	wm := &SessionTeamWorkingMaterial{
+		Client:        extractor,
+		Endpoints:     endpoints,
+		MaxGoroutines: 2,
+		Delay: func(d time.Duration) <-chan time.Time {
+			ch := make(chan time.Time, 1)
+			ch <- time.Now()
+			return ch
+		},
+		CinemaIds:  []string{"1030"},
+		Sampling:   config.Default().Sampling,
+		RegionData: []entities.Region{{Cinemas: []entities.Cinema{{CinemaId: "1030", CinemaName: "Vimercate"}}}},
+	}
+	st := NewSessionTeam(2, wm)
+	rome, err := time.LoadLocation("Europe/Rome")
+	require.NoError(t, err)
+	today := time.Now().In(rome).AddDate(0, 0, 1).Format("2006-01-02")
+	todayFile := filepath.Join(t.TempDir(), "todaySession-"+today+".json")
 
-func (m *MockSessionExtractor) CallSeats(url string) (*entities.Response, error) {
-	data, err := os.ReadFile(FILE_PATH_SEATS_TEST)
-	if err != nil {
-		return nil, err
+	// Planning fetches the showings without seats and writes no file
+	plans, err := st.Plan(context.Background(), today, todayFile, time.Now())
+	require.NoError(t, err)
+	require.Len(t, plans, 2)
+	for _, p := range plans {
+		assert.Zero(t, p.Session.TotalSeats)
 	}
-	var resp entities.Response
-	if err := json.Unmarshal(data, &resp); err != nil {
-		return nil, err
+
+	// The same team still fetches the seats when it runs
+	sessions, _, err := st.Run(context.Background(), today, todayFile, func(ctx context.Context, s entities.ScheduledSession) bool {
+		return true
+	})
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	for _, s := range sessions {
+		assert.Equal(t, 120, s.Session.TotalSeats)
 	}
-	return &resp, nil
 }
 
-func (m *MockSessionExtractor) CallShowings(url string) (*entities.ShowingResponse, error) {
-	data, err := os.ReadFile(FILE_PATH_SESSIONS_TEST)
-	if err != nil {
-		return nil, err
+func TestSessionTeam_ScheduleCancelled(t *testing.T) {
+	wm := &SessionTeamWorkingMaterial{
+		Sampling: config.Default().Sampling,
+		// Timers never fire on their own
+		Delay: func(d time.Duration) <-chan time.Time {
+			return nil
+		},
 	}
-	var resp entities.ShowingResponse
-	if err := json.Unmarshal(data, &resp); err != nil {
-		return nil, err
+	st := NewSessionTeam(1, wm)
+	tomorrow := time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+	sessions := []entities.ScheduledSession{
+		{CinemaId: "1030", Session: entities.Session{SessionId: "1", StartHour: "18:00"}},
+		{CinemaId: "1030", Session: entities.Session{SessionId: "2", StartHour: "21:00"}},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	unsampled := st.scheduleSessionTimers(ctx, tomorrow, sessions, func(ctx context.Context, s entities.ScheduledSession) bool {
+		t.Errorf("callback called for session %s", s.Session.SessionId)
+		return true
+	})
+
+	assert.ElementsMatch(t, sessions, unsampled)
+}
+
+func TestSessionTeam_ScheduleNotSampled(t *testing.T) {
+	wm := &SessionTeamWorkingMaterial{
+		Sampling: config.Default().Sampling,
+		Delay: func(d time.Duration) <-chan time.Time {
+			ch := make(chan time.Time, 1)
+			ch <- time.Now()
+			return ch
+		},
+	}
+	st := NewSessionTeam(1, wm)
+	tomorrow := time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+	sessions := []entities.ScheduledSession{
+		{CinemaId: "1030", Session: entities.Session{SessionId: "1", StartHour: "18:00"}},
+		{CinemaId: "1030", Session: entities.Session{SessionId: "2", StartHour: "21:00"}},
+	}
+
+	// The callback gives up on the second session, e.g. deferred past the shutdown
+	unsampled := st.scheduleSessionTimers(context.Background(), tomorrow, sessions, func(ctx context.Context, s entities.ScheduledSession) bool {
+		return s.Session.SessionId != "2"
+	})
+
+	assert.Equal(t, sessions[1:], unsampled)
+}
+
+func TestSessionTeam_PlanSessions(t *testing.T) {
+	sampling := config.Default().Sampling
+	st := NewSessionTeam(1, &SessionTeamWorkingMaterial{Sampling: sampling})
+	now := time.Date(2025, 9, 15, 18, 0, 0, 0, time.UTC)
+	sessions := []entities.ScheduledSession{
+		{CinemaId: "1030", Session: entities.Session{SessionId: "started", StartHour: "17:00"}},
+		{CinemaId: "1030", Session: entities.Session{SessionId: "evening", StartHour: "21:00"}},
+		{CinemaId: "1030", Session: entities.Session{SessionId: "night", StartHour: "00:30"}},
+		{CinemaId: "1030", Session: entities.Session{SessionId: "broken", StartHour: ""}},
+	}
+
+	plans := st.PlanSessions("2025-09-15", sessions, now)
+
+	assert.Len(t, plans, 4)
+	assert.True(t, plans[0].Skipped)
+	assert.Equal(t, entities.PlanReasonStarted, plans[0].Reason)
+
+	assert.False(t, plans[1].Skipped)
+	assert.Empty(t, plans[1].Reason)
+	assert.Equal(t, plans[1].StartAt.Add(sampling.Offset.Duration()+plans[1].Jitter), plans[1].FireAt)
+	assert.GreaterOrEqual(t, plans[1].Jitter, sampling.JitterMin.Duration())
+	assert.LessOrEqual(t, plans[1].Jitter, sampling.JitterMax.Duration())
+
+	assert.False(t, plans[2].Skipped)
+	assert.Equal(t, entities.PlanReasonRollover, plans[2].Reason)
+	assert.Equal(t, 16, plans[2].StartAt.Day())
+
+	assert.True(t, plans[3].Skipped)
+	assert.Equal(t, entities.PlanReasonUnparseable, plans[3].Reason)
+}
+
+func TestSessionTeam_PlanSessionsInSiteTimeZone(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	require.NoError(t, err)
+	st := NewSessionTeam(1, &SessionTeamWorkingMaterial{Sampling: config.Default().Sampling, Location: london})
+	// 18:00 in Rome is 17:00 in London: the 17:30 London session has not started yet
+	now := time.Date(2025, 9, 15, 18, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+	sessions := []entities.ScheduledSession{
+		{CinemaId: "1", Session: entities.Session{SessionId: "london", StartHour: "17:30"}},
+	}
+
+	plans := st.PlanSessions("2025-09-15", sessions, now)
+
+	require.Len(t, plans, 1)
+	assert.False(t, plans[0].Skipped)
+	assert.Equal(t, london, plans[0].StartAt.Location())
+	assert.Equal(t, time.Date(2025, 9, 15, 16, 30, 0, 0, time.UTC), plans[0].StartAt.UTC())
+}
+
+func TestSessionStartTime(t *testing.T) {
+	tests := []struct {
+		name      string
+		startHour string
+		expected  string
+		expectErr bool
+	}{
+		{name: "evening session", startHour: "21:30", expected: "2025-09-15T21:30:00"},
+		{name: "after midnight rolls over to the next day", startHour: "00:15", expected: "2025-09-16T00:15:00"},
+		{name: "rollover hour is not rolled over", startHour: "06:00", expected: "2025-09-15T06:00:00"},
+		{name: "unparseable start hour", startHour: "9pm", expectErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			startTime, err := SessionStartTime("2025-09-15", tc.startHour, 6, time.UTC)
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, startTime.Format("2006-01-02T15:04:05"))
+		})
 	}
-	return &resp, nil
 }